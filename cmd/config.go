@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/radam9/manga-tools/internal/config"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+const configCommandName = "config"
+
+func NewConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   configCommandName,
+		Short: "manage the config file holding the default flag values",
+		Long: `manage the config file holding the default flag values.
+The config file is read from $XDG_CONFIG_HOME/manga-tools/config.yaml, its top level keys are flag values for every
+command, and the command names are sections with flag values for that command only:
+
+	output: ~/manga
+	download:
+	  language: en
+	  cbz: true
+	  bundle: true
+
+Every flag can also be set with the MANGA_TOOLS_<FLAG> and MANGA_TOOLS_<COMMAND>_<FLAG> environment variables,
+e.g. MANGA_TOOLS_OUTPUT or MANGA_TOOLS_DOWNLOAD_LANGUAGE. The flags passed on the command line always win, followed
+by the environment variables, the command sections and the top level of the config file.`,
+	}
+	cmd.AddCommand(newConfigShowCommand(), newConfigSetCommand(), newConfigPathCommand())
+	return cmd
+}
+
+func newConfigShowCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show",
+		Short: "print the config file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := config.Path()
+			if err != nil {
+				return err
+			}
+			data, err := os.ReadFile(path)
+			if os.IsNotExist(err) {
+				fmt.Fprintf(cmd.OutOrStdout(), "no config file at %s\n", path)
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("reading config file %q: %w", path, err)
+			}
+			_, err = cmd.OutOrStdout().Write(data)
+			return err
+		},
+	}
+}
+
+func newConfigSetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set KEY VALUE",
+		Short: "set the default value of a flag, KEY is FLAG for every command or COMMAND.FLAG for one command",
+		Example: `Download as cbz in english by default
+	$ manga-tools config set download.cbz true
+	$ manga-tools config set download.language en
+
+Write every output into ~/manga
+	$ manga-tools config set output ~/manga`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, value := args[0], args[1]
+			if err := validateConfigKey(cmd.Root(), key); err != nil {
+				return err
+			}
+
+			path, err := config.Path()
+			if err != nil {
+				return err
+			}
+			file, err := config.Load(path)
+			if err != nil {
+				return err
+			}
+			if err := file.Set(key, value); err != nil {
+				return err
+			}
+			return file.Save(path)
+		},
+	}
+}
+
+func newConfigPathCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "path",
+		Short: "print the path of the config file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := config.Path()
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), path)
+			return err
+		},
+	}
+}
+
+// validateConfigKey checks that the key is the flag of a command, or of at least one command if no command is given.
+func validateConfigKey(root *cobra.Command, key string) error {
+	commandName, flagName, found := strings.Cut(key, ".")
+	if !found {
+		flagName = key
+		if root.PersistentFlags().Lookup(flagName) != nil {
+			return nil
+		}
+		for _, command := range root.Commands() {
+			if command.Flags().Lookup(flagName) != nil || command.PersistentFlags().Lookup(flagName) != nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown flag %q", flagName)
+	}
+
+	for _, command := range root.Commands() {
+		if command.Name() != commandName {
+			continue
+		}
+		// the persistent flags of the command are shared by its sub-commands, e.g. library.catalog.
+		if command.Flags().Lookup(flagName) == nil && command.PersistentFlags().Lookup(flagName) == nil &&
+			root.PersistentFlags().Lookup(flagName) == nil {
+			return fmt.Errorf("unknown flag %q for command %q", flagName, commandName)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown command %q", commandName)
+}
+
+// loadConfig sets the flags of the command being executed that were not passed explicitly from the environment and
+// the config file. It's the persistent pre run of the root command, so it runs after the flags are parsed and before
+// they are validated, and the config can fill the required flags. The config, help and completion commands are left
+// alone, so a broken config file can still be shown and located.
+func loadConfig(cmd *cobra.Command, args []string) error {
+	root := cmd.Root()
+	if cmd == root {
+		return nil
+	}
+	// nested commands read the section of their top level command.
+	section := cmd
+	for section.HasParent() && section.Parent() != root {
+		section = section.Parent()
+	}
+	switch section.Name() {
+	case configCommandName, "help", "completion":
+		return nil
+	}
+
+	path, err := config.Path()
+	if err != nil {
+		return nil
+	}
+	file, err := config.Load(path)
+	if err == nil {
+		err = config.Apply(cmd.Flags(), section.Name(), file, os.LookupEnv)
+	}
+	if err != nil {
+		// a broken config isn't a usage error.
+		cmd.SilenceUsage = true
+		return err
+	}
+	return nil
+}
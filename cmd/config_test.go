@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runRootCommand runs the command line through the root command, so the config is loaded like in a real run.
+func runRootCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	rootCmd.SetArgs(args)
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&out)
+	defer func() {
+		rootCmd.SetArgs(nil)
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+	}()
+	err := rootCmd.Execute()
+	return out.String(), err
+}
+
+func TestConfigCommand(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	path := filepath.Join(dir, "manga-tools", "config.yaml")
+
+	t.Run("path", func(t *testing.T) {
+		out, err := runRootCommand(t, "config", "path")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != path+"\n" {
+			t.Errorf("expected: %s, got: %s", path, out)
+		}
+	})
+
+	t.Run("show without a config file", func(t *testing.T) {
+		out, err := runRootCommand(t, "config", "show")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := "no config file at " + path + "\n"; out != expected {
+			t.Errorf("expected: %s, got: %s", expected, out)
+		}
+	})
+
+	t.Run("set and show", func(t *testing.T) {
+		if _, err := runRootCommand(t, "config", "set", "download.language", "en"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := runRootCommand(t, "config", "set", "output", "/tmp/manga"); err != nil {
+			t.Fatal(err)
+		}
+		out, err := runRootCommand(t, "config", "show")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, expected := range []string{"output: /tmp/manga\n", "download:\n", "  language: en\n"} {
+			if !strings.Contains(out, expected) {
+				t.Errorf("expected: %q in the config, got: %s", expected, out)
+			}
+		}
+	})
+
+	t.Run("set unknown flag", func(t *testing.T) {
+		if _, err := runRootCommand(t, "config", "set", "download.unknown", "true"); err == nil {
+			t.Errorf("expected an error for an unknown flag")
+		}
+	})
+
+	t.Run("broken config file", func(t *testing.T) {
+		broken := "output: [\n"
+		if err := os.WriteFile(path, []byte(broken), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// the config commands still work, to look at the broken file.
+		out, err := runRootCommand(t, "config", "show")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != broken {
+			t.Errorf("expected: %s, got: %s", broken, out)
+		}
+		if _, err := runRootCommand(t, "config", "path"); err != nil {
+			t.Fatal(err)
+		}
+
+		// the other commands fail with the config error.
+		_, err = runRootCommand(t, "library", "list", "--catalog", filepath.Join(dir, "library.json"))
+		if err == nil || !strings.Contains(err.Error(), "parsing config file") {
+			t.Errorf("expected: an error parsing the config file, got: %v", err)
+		}
+	})
+}
@@ -1,9 +1,11 @@
 package cmd
 
 import (
+	"cmp"
 	"fmt"
 	"github.com/radam9/manga-tools/internal"
 	"github.com/radam9/manga-tools/internal/format"
+	"github.com/radam9/manga-tools/internal/images"
 	"github.com/radam9/manga-tools/internal/model"
 	"github.com/spf13/cobra"
 	"log/slog"
@@ -13,14 +15,23 @@ import (
 	"strings"
 )
 
-var imageFormats = []string{".jpg", ".jpeg", ".png"}
-var archiveFormats = []string{".cbr", ".cbz"}
+var imageFormats = internal.ImageExtensions
+var archiveFormats = []string{".cbr", ".cbz", ".cb7", ".cbt"}
+var pdfFormats = []string{".pdf"}
 
 type convertOptions struct {
 	dir         string
 	archiveMode bool
 	imageMode   bool
+	pdfMode     bool
+	recursive   bool
+	depth       int
+	groupDepth  int
 	bundle      bool
+	to          string
+	images      imageProcessingOptions
+	direction   string
+	pdf         pdfFlags
 }
 
 func NewConvertCommand() *cobra.Command {
@@ -28,18 +39,41 @@ func NewConvertCommand() *cobra.Command {
 
 	cmd := &cobra.Command{
 		Use:   "convert [OPTIONS]",
-		Short: "convert a set of images, cbr or cbz files to pdf",
-		Long: `convert a set of images, cbr or cbz files to pdf.
-By default the command will try to convert images to pdf, pass the appropriate flag to convert to a different format.
+		Short: "convert a set of images, cbr, cbz, cb7, cbt or pdf files to pdf, cbz, cbr, cb7, cbt, epub or images",
+		Long: `convert a set of images, cbr, cbz, cb7, cbt or pdf files to pdf, cbz, cbr, cb7, cbt, epub or image folders.
+By default the command will try to convert images to pdf, pass the appropriate flag to convert from a different format,
+and --to to convert to a different format.
 
-The items are converted to a pdf and bundled following the rules below:
-- Each sub-directory of the given directory will be converted to a separate pdf.
-- All children files of the given directory will be converted to a single pdf if they are images,
-	If they are archives, each archive will be converted to separate pdf.
+Images can be jpg, png, webp, gif (first frame only), tiff or avif, unsupported files are skipped with a warning.
+The pages of pdf files are their embedded images, a page made of several images is stacked into one image
+and a page without images (text or vector drawings) is skipped with a warning.
 
-If the bundle flag is passed, the everything will bundled in a single pdf in the following order:
+The items are converted to output files and bundled following the rules below:
+- Each sub-directory of the given directory will be converted to a separate output file.
+- All children files of the given directory will be converted to a single output file if they are images,
+	If they are archives or pdfs, each file will be converted to separate output file.
+
+With --recursive the nested directories are converted too, down to --depth levels below the given directory:
+- By default every directory holding files is converted to a separate output file, named after its path,
+	e.g. "Volume 01 - Chapter 001" for Series/Volume 01/Chapter 001.
+- With --group-depth N every directory N levels below the given directory is converted with everything below it
+	to a single output file, e.g. one file per volume with --group-depth 1.
+The directories and files are ordered naturally at every level, a directory coming after its sub-directories.
+
+If the bundle flag is passed, the everything will bundled in a single output file in the following order:
 	- The items of each sub-directory of the provided directory will be ordered by path and 
 		the files in the root of the dir will be appended at the end.`,
+		Example: `Repack a folder of chapter image directories into one cbz per chapter
+	$ manga-tools convert -d ./slam-dunk --to cbz
+
+Convert cbr archives to a single epub
+	$ manga-tools convert -d ./slam-dunk --archive --to epub --bundle
+
+Convert a series tree to one epub per volume
+	$ manga-tools convert -d ./slam-dunk --recursive --group-depth 1 --to epub
+
+Convert scanlation pdfs to cbz
+	$ manga-tools convert -d ./slam-dunk --pdf-input --to cbz`,
 		Args: cobra.NoArgs,
 		RunE: convertCommandRunFunction(options),
 	}
@@ -56,11 +90,20 @@ If the bundle flag is passed, the everything will bundled in a single pdf in the
 
 	const convertArchiveModeFlag = "archive"
 	const convertImageModeFlag = "image"
-	flags.BoolVar(&options.archiveMode, convertArchiveModeFlag, false, "source files are archive format (cbr/cbz)")
+	const convertPDFModeFlag = "pdf-input"
+	flags.BoolVar(&options.archiveMode, convertArchiveModeFlag, false, "source files are archive format (cbr/cbz/cb7/cbt)")
 	flags.BoolVar(&options.imageMode, convertImageModeFlag, false, "source files are image format")
-	cmd.MarkFlagsMutuallyExclusive(convertArchiveModeFlag, convertImageModeFlag)
+	flags.BoolVar(&options.pdfMode, convertPDFModeFlag, false, "source files are pdf format")
+	cmd.MarkFlagsMutuallyExclusive(convertArchiveModeFlag, convertImageModeFlag, convertPDFModeFlag)
 
-	flags.BoolVarP(&options.bundle, "bundle", "b", false, "bundle all passed dir and files into a single pdf")
+	flags.BoolVarP(&options.recursive, "recursive", "r", false, "convert the nested directories too")
+	flags.IntVar(&options.depth, "depth", 0, "how many directory levels below the given directory are converted with --recursive, 0 has no limit")
+	flags.IntVar(&options.groupDepth, "group-depth", 0, "depth of the directories converted to a single output file with everything below them, 1 being the sub-directories of the given directory (default converts every directory holding files on its own)")
+	flags.BoolVarP(&options.bundle, "bundle", "b", false, "bundle all passed dir and files into a single output file")
+	flags.StringVar(&options.to, "to", "pdf", fmt.Sprintf("output format %v", format.Formats))
+	flags.StringVar(&options.direction, "direction", directionAuto, fmt.Sprintf("reading direction of the output %v, auto uses the ComicInfo of the archives or the viewer preferences of the pdfs, and ltr if they have none", model.Directions))
+	addPDFFlags(flags, &options.pdf)
+	addImageProcessingFlags(flags, &options.images)
 	return cmd
 }
 
@@ -75,12 +118,34 @@ func convertCommandRunFunction(options *convertOptions) func(cmd *cobra.Command,
 		}
 		defer os.RemoveAll(tempDir)
 
+		// images are the default source format
+		options.imageMode = !options.archiveMode && !options.pdfMode
+
+		if options.depth < 0 || options.groupDepth < 0 {
+			return fmt.Errorf("--depth and --group-depth can't be negative")
+		}
 		rootName, items, err := parseDirs(options)
 		if err != nil {
 			return err
 		}
 
-		return writeOutputPDF(tempDir, items, rootName, options.imageMode, options.bundle)
+		pdfOptions, err := options.pdf.options()
+		if err != nil {
+			return err
+		}
+		saver, err := format.NewFormat(options.to, format.LayoutFlat, pdfOptions)
+		if err != nil {
+			return err
+		}
+		pipeline, err := options.images.pipeline(cmd.Flags())
+		if err != nil {
+			return err
+		}
+		direction, err := parseDirection(options.direction)
+		if err != nil {
+			return err
+		}
+		return writeOutput(saver, pipeline, direction, tempDir, items, rootName, options)
 	}
 }
 
@@ -88,6 +153,8 @@ func parseDirs(options *convertOptions) (string, []convertOutputUnit, error) {
 	allowedFormats := imageFormats
 	if options.archiveMode {
 		allowedFormats = archiveFormats
+	} else if options.pdfMode {
+		allowedFormats = pdfFormats
 	}
 
 	var rootItem convertOutputUnit
@@ -103,14 +170,15 @@ func parseDirs(options *convertOptions) (string, []convertOutputUnit, error) {
 	for _, child := range children {
 		isAllowedFormat := slices.Contains(allowedFormats, filepath.Ext(strings.ToLower(child.Name())))
 		if !child.IsDir() && !isAllowedFormat {
+			slog.Warn("skipping unsupported file", "path", filepath.Join(options.dir, child.Name()))
 			continue
 		}
 
 		childPath := filepath.Join(options.dir, child.Name())
 		if !child.IsDir() && isAllowedFormat {
-			if (options.imageMode) || (options.archiveMode && options.bundle) {
+			if options.imageMode || options.bundle {
 				rootItem.appendFile(childPath)
-			} else if options.archiveMode && !options.bundle {
+			} else {
 				item := convertOutputUnit{
 					dir:   options.dir,
 					name:  strings.TrimSuffix(child.Name(), filepath.Ext(child.Name())),
@@ -122,82 +190,184 @@ func parseDirs(options *convertOptions) (string, []convertOutputUnit, error) {
 		}
 
 		// child is directory
-		files, err := os.ReadDir(childPath)
-		if err != nil {
-			return "", nil, fmt.Errorf("listing directory %q contents: %w", childPath, err)
+		if err := walkDir(options, allowedFormats, childPath, 1, []string{child.Name()}, nil, &items); err != nil {
+			return "", nil, err
 		}
-		files = internal.SortDirEntry(files)
+	}
+	items = append(items, rootItem)
 
-		subDirOutput := convertOutputUnit{dir: childPath, name: filepath.Base(child.Name())}
-		for _, file := range files {
-			isAllowedFormat := slices.Contains(allowedFormats, filepath.Ext(strings.ToLower(file.Name())))
-			if (!file.IsDir() && !isAllowedFormat) || file.IsDir() {
+	return rootItem.name, items, nil
+}
+
+// walkDir adds the files of a directory at the given depth below the converted directory to the output units.
+// The directories at the group depth are converted with everything below them to one output, otherwise
+// every directory holding files is converted to its own output, named after its path from the converted directory.
+// A directory is appended after its sub-directories, like the files of the converted directory.
+func walkDir(options *convertOptions, allowedFormats []string, dir string, depth int, names []string, unit *convertOutputUnit, items *[]convertOutputUnit) error {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("listing directory %q contents: %w", dir, err)
+	}
+	files = internal.SortDirEntry(files)
+
+	// the unit of a group directory collects the files below it, other units only the files of their directory.
+	var own *convertOutputUnit
+	if unit == nil && options.groupDepth > 0 && depth >= options.groupDepth {
+		own = &convertOutputUnit{dir: dir, name: strings.Join(names, " - ")}
+		unit = own
+	}
+	groupUnit := unit
+	for _, file := range files {
+		filePath := filepath.Join(dir, file.Name())
+		if file.IsDir() {
+			if !options.recursive || (options.depth > 0 && depth >= options.depth) {
+				slog.Warn("skipping nested directory", "path", filePath)
 				continue
 			}
-			subDirOutput.appendFile(filepath.Join(childPath, file.Name()))
+			if err := walkDir(options, allowedFormats, filePath, depth+1, append(slices.Clone(names), file.Name()), groupUnit, items); err != nil {
+				return err
+			}
+			continue
+		}
+		if !slices.Contains(allowedFormats, filepath.Ext(strings.ToLower(file.Name()))) {
+			slog.Warn("skipping unsupported file", "path", filePath)
+			continue
+		}
+		if unit == nil {
+			// files above the group depth are converted on their own.
+			own = &convertOutputUnit{dir: dir, name: strings.Join(names, " - ")}
+			unit = own
 		}
-		items = append(items, subDirOutput)
+		unit.appendFile(filePath)
 	}
-	items = append(items, rootItem)
 
-	return rootItem.name, items, nil
+	if own != nil && len(own.files) > 0 {
+		*items = append(*items, *own)
+	}
+	return nil
 }
 
-func writeOutputPDF(tempDir string, items []convertOutputUnit, rootName string, imageMode, bundle bool) error {
-	pdf := format.PDF{}
+func writeOutput(saver format.Format, pipeline images.Pipeline, direction model.Direction, tempDir string, items []convertOutputUnit, rootName string, options *convertOptions) error {
+	// without a --direction every output keeps the direction of its inputs, a bundle the first one found.
+	directions := make([]model.Direction, len(items))
+	for i, item := range items {
+		directions[i] = cmp.Or(direction, item.sourceDirection(options))
+	}
+	bundleDirection := cmp.Or(append(slices.Clone(directions), model.DirectionLTR)...)
 
-	var images []model.FilePath
-	for _, item := range items {
-		result, err := item.getImages(tempDir, imageMode)
+	bundleBook := model.Book{Manga: model.Manga{Title: rootName, Direction: bundleDirection}}
+	for i, item := range items {
+		itemDirection := cmp.Or(directions[i], model.DirectionLTR)
+		if options.bundle {
+			itemDirection = bundleDirection
+		}
+		result, err := item.getImages(tempDir, options)
 		if err != nil {
 			return fmt.Errorf("getting images for %q: %w", item.dir, err)
 		}
-		if bundle {
-			images = append(images, result...)
+		pages, err := processPages(model.NewPagesFromPaths(result), tempDir, pipeline, itemDirection)
+		if err != nil {
+			return fmt.Errorf("processing images for %q: %w", item.dir, err)
+		}
+		chapter := model.Chapter{Title: item.name, Pages: pages}
+		if options.bundle {
+			bundleBook.Chapters = append(bundleBook.Chapters, chapter)
 			continue
 		}
 
-		outputFilePath := filepath.Join(OutputDir, fmt.Sprintf("%s.pdf", item.name))
-		if err := pdf.Save(outputFilePath, result); err != nil {
-			return fmt.Errorf("saving pdf %q: %w", outputFilePath, err)
+		if len(chapter.Pages) == 0 {
+			continue
+		}
+		outputFilePath := saver.OutputPath(OutputDir, item.name, 0, "", 0)
+		if slices.ContainsFunc(item.files, func(path model.FilePath) bool { return sameFile(path, outputFilePath) }) {
+			return fmt.Errorf("saving %q would overwrite its source, pass a different --output directory", outputFilePath)
+		}
+		book := model.Book{Manga: model.Manga{Title: item.name, Direction: itemDirection}, Chapters: []model.Chapter{chapter}}
+		if err := saver.Save(outputFilePath, book); err != nil {
+			return fmt.Errorf("saving %q: %w", outputFilePath, err)
 		}
 	}
 
-	if bundle {
-		outputFilePath := filepath.Join(OutputDir, fmt.Sprintf("%s.pdf", rootName))
-		if err := pdf.Save(outputFilePath, images); err != nil {
-			return fmt.Errorf("saving pdf %q: %w", outputFilePath, err)
+	if options.bundle {
+		outputFilePath := saver.OutputPath(OutputDir, rootName, 0, "", 0)
+		if err := saver.Save(outputFilePath, bundleBook); err != nil {
+			return fmt.Errorf("saving %q: %w", outputFilePath, err)
 		}
 	}
 	return nil
 }
 
+func sameFile(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
 type convertOutputUnit struct {
 	dir   string
 	name  string
 	files []model.FilePath
 }
 
-func (c convertOutputUnit) getImages(tempDir string, imageMode bool) ([]string, error) {
+func (c convertOutputUnit) getImages(tempDir string, options *convertOptions) ([]string, error) {
 	var images []string
-	if imageMode {
+	if options.imageMode {
 		for _, image := range c.files {
 			images = append(images, image)
 		}
 		return images, nil
 	}
 
-	// archive mode
-	for _, archive := range c.files {
-		imagePaths, err := format.ExtractArchive(tempDir, c.dir, filepath.Base(archive))
+	// archive and pdf modes, every input is extracted into its own directory, as the inputs of a unit, and the units of
+	// a bundle, can come from different directories sharing a file name.
+	unitDir, err := os.MkdirTemp(tempDir, "unit-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating temp directory: %w", err)
+	}
+	for i, file := range c.files {
+		inputDir := filepath.Join(unitDir, fmt.Sprint(i))
+		var imagePaths []model.FilePath
+		if options.pdfMode {
+			imagePaths, err = format.ExtractPDF(inputDir, file)
+		} else {
+			imagePaths, err = format.ExtractArchive(inputDir, file)
+		}
 		if err != nil {
-			return nil, fmt.Errorf("extracting archive %q: %w", archive, err)
+			return nil, fmt.Errorf("extracting %q: %w", file, err)
 		}
 		images = append(images, imagePaths...)
 	}
 	return images, nil
 }
 
+// sourceDirection returns the reading direction recorded by the first input of the unit that has one, in the ComicInfo
+// of comic archives or the viewer preferences of pdfs. Images don't record any.
+func (c convertOutputUnit) sourceDirection(options *convertOptions) model.Direction {
+	for _, file := range c.files {
+		switch {
+		case options.pdfMode:
+			info, err := format.ReadPDFInfo(file)
+			if err != nil {
+				slog.Warn("reading the reading direction of the pdf", "path", file, "error", err)
+				continue
+			}
+			if info.Direction != "" {
+				return info.Direction
+			}
+		case options.archiveMode:
+			info, err := format.ReadArchiveComicInfo(file)
+			if err != nil {
+				slog.Warn("reading the reading direction of the archive", "path", file, "error", err)
+				continue
+			}
+			if info != nil && info.Direction() != "" {
+				return info.Direction()
+			}
+		}
+	}
+	return ""
+}
+
 func (c *convertOutputUnit) appendFile(newFile model.FilePath) {
 	currentFiles := c.files
 	currentFiles = append(currentFiles, newFile)
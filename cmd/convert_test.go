@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	"archive/zip"
+	"github.com/radam9/manga-tools/internal/format"
+	"github.com/radam9/manga-tools/internal/images"
+	"github.com/radam9/manga-tools/internal/model"
+	"github.com/radam9/manga-tools/internal/testutil"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseDirsRecursive(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "Series")
+	for _, path := range []string{
+		"Volume 10/Chapter 100/1.png",
+		"Volume 2/Chapter 10/1.png",
+		"Volume 2/Chapter 9/2.png",
+		"Volume 2/Chapter 9/10.png",
+		"Volume 2/cover.png",
+	} {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		options  convertOptions
+		expected map[string][]string
+		order    []string
+	}{
+		{
+			name:    "one level deep",
+			options: convertOptions{},
+			expected: map[string][]string{
+				"Volume 2": {"Volume 2/cover.png"},
+			},
+		},
+		{
+			name:    "every directory holding files",
+			options: convertOptions{recursive: true},
+			expected: map[string][]string{
+				"Volume 2 - Chapter 9":    {"Volume 2/Chapter 9/2.png", "Volume 2/Chapter 9/10.png"},
+				"Volume 2 - Chapter 10":   {"Volume 2/Chapter 10/1.png"},
+				"Volume 2":                {"Volume 2/cover.png"},
+				"Volume 10 - Chapter 100": {"Volume 10/Chapter 100/1.png"},
+			},
+			order: []string{"Volume 2 - Chapter 9", "Volume 2 - Chapter 10", "Volume 2", "Volume 10 - Chapter 100"},
+		},
+		{
+			name:    "grouped by volume",
+			options: convertOptions{recursive: true, groupDepth: 1},
+			expected: map[string][]string{
+				"Volume 2":  {"Volume 2/Chapter 9/2.png", "Volume 2/Chapter 9/10.png", "Volume 2/Chapter 10/1.png", "Volume 2/cover.png"},
+				"Volume 10": {"Volume 10/Chapter 100/1.png"},
+			},
+		},
+		{
+			name:    "depth limit",
+			options: convertOptions{recursive: true, depth: 1},
+			expected: map[string][]string{
+				"Volume 2": {"Volume 2/cover.png"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := tt.options
+			options.dir = root
+			options.imageMode = true
+			_, items, err := parseDirs(&options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := map[string][]string{}
+			var names []string
+			for _, item := range items {
+				if len(item.files) == 0 {
+					continue
+				}
+				names = append(names, item.name)
+				for _, file := range item.files {
+					rel, _ := filepath.Rel(root, file)
+					result[item.name] = append(result[item.name], filepath.ToSlash(rel))
+				}
+			}
+			for name, files := range tt.expected {
+				if !slices.Equal(result[name], files) {
+					t.Errorf("expected: %s: %v, got: %v", name, files, result[name])
+				}
+			}
+			if len(names) != len(tt.expected) {
+				t.Errorf("expected: %d outputs, got: %v", len(tt.expected), names)
+			}
+			if tt.order != nil && !slices.Equal(names, tt.order) {
+				t.Errorf("expected: %v, got: %v", tt.order, names)
+			}
+		})
+	}
+}
+
+// writeTestArchive writes a cbz of a single page of the given width.
+func writeTestArchive(t *testing.T, path string, width int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	entry, err := w.Create("001.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(entry, image.NewGray(image.Rect(0, 0, width, 100))); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConvertSameNamedArchives(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "Series")
+	writeTestArchive(t, filepath.Join(root, "Volume 1", "Chapter 1", "01.cbz"), 50)
+	writeTestArchive(t, filepath.Join(root, "Volume 1", "Chapter 2", "01.cbz"), 70)
+
+	outputDir := OutputDir
+	OutputDir = t.TempDir()
+	defer func() { OutputDir = outputDir }()
+
+	options := &convertOptions{dir: root, archiveMode: true, recursive: true, groupDepth: 1}
+	rootName, items, err := parseDirs(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saver, err := format.NewFormat("cbz", format.LayoutFlat, format.PDFOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeOutput(saver, images.Pipeline{}, model.DirectionLTR, t.TempDir(), items, rootName, options); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.OpenReader(saver.OutputPath(OutputDir, "Volume 1", 0, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var widths []int
+	for _, f := range r.File {
+		if filepath.Ext(f.Name) != ".png" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		config, _, err := image.DecodeConfig(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		widths = append(widths, config.Width)
+	}
+	if !slices.Equal(widths, []int{50, 70}) {
+		t.Errorf("expected: %v, got: %v", []int{50, 70}, widths)
+	}
+}
+
+func TestConvertKeepsSourceDirection(t *testing.T) {
+	page := filepath.Join(t.TempDir(), "001.png")
+	testutil.WritePNG(t, page, 10, 20)
+	book := model.Book{
+		Manga:    model.Manga{Title: "Slam Dunk", Direction: model.DirectionRTL},
+		Chapters: []model.Chapter{{Number: 1, Pages: model.NewPagesFromPaths([]model.FilePath{page})}},
+	}
+
+	tests := []struct {
+		name      string
+		source    format.Format
+		options   convertOptions
+		direction model.Direction
+		expected  model.Direction
+	}{
+		{name: "archive", source: format.CBZ{}, options: convertOptions{archiveMode: true}, expected: model.DirectionRTL},
+		{name: "pdf", source: format.PDF{}, options: convertOptions{pdfMode: true}, expected: model.DirectionRTL},
+		{name: "overridden", source: format.CBZ{}, options: convertOptions{archiveMode: true}, direction: model.DirectionLTR, expected: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := filepath.Join(t.TempDir(), "Series")
+			if err := tt.source.Save(tt.source.OutputPath(root, "Chapter 1", 0, "", 0), book); err != nil {
+				t.Fatal(err)
+			}
+
+			outputDir := OutputDir
+			OutputDir = t.TempDir()
+			defer func() { OutputDir = outputDir }()
+
+			options := tt.options
+			options.dir = root
+			rootName, items, err := parseDirs(&options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			saver := format.CBZ{}
+			if err := writeOutput(saver, images.Pipeline{}, tt.direction, t.TempDir(), items, rootName, &options); err != nil {
+				t.Fatal(err)
+			}
+			info, err := format.ReadArchiveComicInfo(saver.OutputPath(OutputDir, "Chapter 1", 0, "", 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if direction := info.Direction(); direction != tt.expected {
+				t.Errorf("expected: %q, got: %q", tt.expected, direction)
+			}
+		})
+	}
+}
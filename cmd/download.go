@@ -3,7 +3,9 @@ package cmd
 import (
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/radam9/manga-tools/internal"
 	"github.com/radam9/manga-tools/internal/format"
+	"github.com/radam9/manga-tools/internal/library"
 	"github.com/radam9/manga-tools/internal/mangadex"
 	"github.com/radam9/manga-tools/internal/model"
 	"github.com/radam9/manga-tools/internal/ranges"
@@ -11,24 +13,39 @@ import (
 	"log/slog"
 	"net/url"
 	"os"
+	"slices"
 	"strings"
 	"sync"
 )
 
 const (
-	maxChapterConcurrency = 5
-	maxPageConcurrency    = 10
+	defaultChapterWorkers     = 5
+	defaultPageWorkers        = 10
+	defaultConnectionsPerHost = 8
 )
 
 type DownloadOptions struct {
-	language     string
-	bundle       bool
-	bundleVolume bool
-	chapterRange string
-	image        bool
-	cbr          bool
-	cbz          bool
-	pdf          bool
+	language        string
+	bundle          bool
+	bundleVolume    bool
+	chapterRange    string
+	image           bool
+	cbr             bool
+	cbz             bool
+	cb7             bool
+	cbt             bool
+	pdf             bool
+	epub            bool
+	layout          string
+	images          imageProcessingOptions
+	direction       string
+	pdfOptions      pdfFlags
+	chapterWorkers  int
+	pageWorkers     int
+	limitRate       string
+	hostConnections int
+	catalog         string
+	skipOwned       bool
 }
 
 func NewDownloadCommand() *cobra.Command {
@@ -38,7 +55,10 @@ func NewDownloadCommand() *cobra.Command {
 		Use:   "download URL/ID",
 		Short: "downloads a manga from mangadex given a url/id",
 		Long: `downloads a manga from mangadex given the url or id of the manga.
-By default the manga is downloaded as image files, specify the appropriate flag to download in a different format.`,
+By default the manga is downloaded as image files, specify the appropriate flag to download in a different format.
+
+The chapters already in the library catalog (see library scan) are reported, and skipped with --skip-owned.
+Every output directory gets a manga-tools.json manifest of the downloaded files, read back by library scan.`,
 		Example: `Download entire manga using url
 	$ manga-tools download https://mangadex.org/title/319df2e2-e6a6-4e3a-a31c-68539c140a84/slam-dunk
 
@@ -54,6 +74,21 @@ Download manga as cbz and bundle into one file
 Download range of chapters
 	$ manga-tools download https://mangadex.org/title/319df2e2-e6a6-4e3a-a31c-68539c140a84/slam-dunk -c 1-20
 
+Download manga as cbz resized and in grayscale for a kindle paperwhite
+	$ manga-tools download https://mangadex.org/title/319df2e2-e6a6-4e3a-a31c-68539c140a84/slam-dunk --cbz --profile kindle-paperwhite
+
+Download manga as pdf read right to left, displaying two pages side by side
+	$ manga-tools download https://mangadex.org/title/319df2e2-e6a6-4e3a-a31c-68539c140a84/slam-dunk --pdf --direction rtl --two-page
+
+Download manga on a shared connection, with at most 2 chapters at once and 2MB/s
+	$ manga-tools download https://mangadex.org/title/319df2e2-e6a6-4e3a-a31c-68539c140a84/slam-dunk --cbz --chapter-workers 2 --limit-rate 2MB/s
+
+Download manga as cbz into "<output>/<series>/Volume NN/" directories
+	$ manga-tools download https://mangadex.org/title/319df2e2-e6a6-4e3a-a31c-68539c140a84/slam-dunk --cbz --layout volume
+
+Download the new chapters only, skipping the ones already in the library catalog
+	$ manga-tools download https://mangadex.org/title/319df2e2-e6a6-4e3a-a31c-68539c140a84/slam-dunk --cbz -c 1-50 --skip-owned
+
 Ranges can take the following forms:
 	- 1-20
 	- 1,2,5-10
@@ -76,12 +111,31 @@ Ranges can take the following forms:
 	const downloadImageFormatFlag = "image"
 	const downloadCBRFormatFlag = "cbr"
 	const downloadCBZFormatFlag = "cbz"
+	const downloadCB7FormatFlag = "cb7"
+	const downloadCBTFormatFlag = "cbt"
 	const downloadPDFFormatFlag = "pdf"
+	const downloadEPUBFormatFlag = "epub"
 	flags.BoolVar(&options.image, downloadImageFormatFlag, false, "download manga in image format")
-	flags.BoolVar(&options.image, downloadCBRFormatFlag, false, "download manga in CBR format")
-	flags.BoolVar(&options.image, downloadCBZFormatFlag, false, "download manga in CBZ format")
-	flags.BoolVar(&options.image, downloadPDFFormatFlag, false, "download manga in PDF format")
-	cmd.MarkFlagsMutuallyExclusive(downloadImageFormatFlag, downloadCBRFormatFlag, downloadCBZFormatFlag, downloadPDFFormatFlag)
+	flags.BoolVar(&options.cbr, downloadCBRFormatFlag, false, "download manga in CBR format")
+	flags.BoolVar(&options.cbz, downloadCBZFormatFlag, false, "download manga in CBZ format")
+	flags.BoolVar(&options.cb7, downloadCB7FormatFlag, false, "download manga in CB7 format")
+	flags.BoolVar(&options.cbt, downloadCBTFormatFlag, false, "download manga in CBT format")
+	flags.BoolVar(&options.pdf, downloadPDFFormatFlag, false, "download manga in PDF format")
+	flags.BoolVar(&options.epub, downloadEPUBFormatFlag, false, "download manga in fixed-layout EPUB format")
+	addImageProcessingFlags(flags, &options.images)
+	cmd.MarkFlagsMutuallyExclusive(downloadImageFormatFlag, downloadCBRFormatFlag, downloadCBZFormatFlag, downloadCB7FormatFlag, downloadCBTFormatFlag, downloadPDFFormatFlag, downloadEPUBFormatFlag)
+
+	flags.StringVar(&options.direction, "direction", directionAuto, fmt.Sprintf("reading direction of the output %v, auto uses the original language and tags of the manga", model.Directions))
+	addPDFFlags(flags, &options.pdfOptions)
+	flags.StringVar(&options.layout, "layout", string(format.LayoutFlat), fmt.Sprintf("directory layout of the output files %v", format.Layouts))
+
+	flags.IntVar(&options.chapterWorkers, "chapter-workers", defaultChapterWorkers, "number of chapters downloaded at once")
+	flags.IntVar(&options.pageWorkers, "page-workers", defaultPageWorkers, "number of pages of a chapter downloaded at once")
+	flags.StringVar(&options.limitRate, "limit-rate", "", "bandwidth cap of all the page downloads together, e.g. 2MB/s (default unlimited)")
+	flags.IntVar(&options.hostConnections, "host-connections", defaultConnectionsPerHost, "maximum concurrent downloads from a single MangaDex@Home server, 0 for unlimited")
+
+	flags.StringVar(&options.catalog, "catalog", "", "path to the library catalog file (default is $XDG_CONFIG_HOME/manga-tools/library.json)")
+	flags.BoolVar(&options.skipOwned, "skip-owned", false, "skip the chapters already in the library catalog")
 	return cmd
 }
 
@@ -92,14 +146,45 @@ func downloadCommandRunFunction(options *DownloadOptions) func(cmd *cobra.Comman
 			return err
 		}
 
-		saver := format.SelectFormat(options.cbr, options.cbz, options.pdf)
+		layout, err := format.ParseLayout(options.layout)
+		if err != nil {
+			return err
+		}
+		pipeline, err := options.images.pipeline(cmd.Flags())
+		if err != nil {
+			return err
+		}
+		direction, err := parseDirection(options.direction)
+		if err != nil {
+			return err
+		}
+		pdfOptions, err := options.pdfOptions.options()
+		if err != nil {
+			return err
+		}
+		saver := format.SelectFormat(options.cbr, options.cbz, options.cb7, options.cbt, options.pdf, options.epub, layout, pdfOptions)
+
+		if options.chapterWorkers <= 0 || options.pageWorkers <= 0 {
+			return fmt.Errorf("chapter workers %d and page workers %d must be positive", options.chapterWorkers, options.pageWorkers)
+		}
+		var bytesPerSecond int64
+		if options.limitRate != "" {
+			if bytesPerSecond, err = internal.ParseSize(strings.TrimSuffix(options.limitRate, "/s")); err != nil {
+				return fmt.Errorf("parsing limit rate: %w", err)
+			}
+		}
+		limits := mangadex.Limits{BytesPerSecond: bytesPerSecond, ConnectionsPerHost: options.hostConnections}
 
-		client := mangadex.NewClient(mangaID, options.language)
-		mangaTitle, err := client.FetchTitle()
+		client := mangadex.NewClient(mangaID, options.language, limits)
+		manga, err := client.FetchManga()
 		if err != nil {
-			slog.Error("fetching manga title", "error", err)
+			slog.Error("fetching manga", "error", err)
 			return err
 		}
+		if direction != "" {
+			manga.Direction = direction
+		}
+		mangaTitle := manga.Title
 
 		chapters, errs := client.FetchChapterList()
 		if len(errs) > 0 {
@@ -125,6 +210,7 @@ func downloadCommandRunFunction(options *DownloadOptions) func(cmd *cobra.Comman
 		}
 
 		chapters = model.FilterChapters(chapters, chapterRanges)
+		chapters = filterOwnedChapters(options, mangaTitle, chapters)
 		if len(chapters) == 0 {
 			slog.Info("no manga chapters found")
 			os.Exit(0)
@@ -136,8 +222,11 @@ func downloadCommandRunFunction(options *DownloadOptions) func(cmd *cobra.Comman
 		}
 		defer os.RemoveAll(tempDir)
 
+		// saved are the books written, by file path, for the download manifests.
+		saved := map[string]model.Book{}
+		savedMu := sync.Mutex{}
 		wg := sync.WaitGroup{}
-		guard := make(chan struct{}, maxChapterConcurrency)
+		guard := make(chan struct{}, options.chapterWorkers)
 
 		for i := range len(chapters) {
 			guard <- struct{}{}
@@ -146,7 +235,7 @@ func downloadCommandRunFunction(options *DownloadOptions) func(cmd *cobra.Comman
 				defer wg.Done()
 
 				slog.Info("fetching chapter", "chapterID", chapter.ID, "chapterTitle", chapter.Title)
-				err = client.FetchChapterInfo(chapter)
+				err := client.FetchChapterInfo(chapter)
 				if err != nil {
 					slog.Error("fetching chapter", "chapterID", chapter.ID, "chapterTitle", chapter.Title, "error", err)
 					<-guard
@@ -154,7 +243,7 @@ func downloadCommandRunFunction(options *DownloadOptions) func(cmd *cobra.Comman
 				}
 
 				slog.Info("downloading chapter pages", "chapterID", chapter.ID, "chapterTitle", chapter.Title)
-				pages, err := client.FetchChapterPages(chapter.Number, chapter.ID, chapter.Pages, maxPageConcurrency)
+				pages, err := client.FetchChapterPages(chapter.Number, chapter.ID, chapter.Pages, options.pageWorkers)
 				if err != nil {
 					slog.Error("downloading chapter pages", "chapterID", chapter.ID, "chapterTitle", chapter.Title, "error", err)
 					<-guard
@@ -167,12 +256,23 @@ func downloadCommandRunFunction(options *DownloadOptions) func(cmd *cobra.Comman
 				if chapter.Pages, err = mangadex.WritePagesToTempFiles(tempDir, pages); err != nil {
 					slog.Error("writing page to temp files", "error", err)
 				}
+				if chapter.Pages, err = processPages(chapter.Pages, tempDir, pipeline, manga.Direction); err != nil {
+					slog.Error("processing chapter pages", "chapterID", chapter.ID, "chapterTitle", chapter.Title, "error", err)
+					chapter.Pages = nil
+					<-guard
+					return
+				}
 
 				if !options.bundle && !options.bundleVolume {
 					filename := saver.OutputPath(OutputDir, mangaTitle, chapter.Volume, chapter.Title, chapter.Number)
 					slog.Info("writing output file", "filepath", filename)
-					if err := saver.Save(filename, model.GetSliceOfPagePathsFromPages(pages)); err != nil {
+					book := model.Book{Manga: manga, Volume: chapter.Volume, Chapters: []model.Chapter{*chapter}}
+					if err := saver.Save(filename, book); err != nil {
 						slog.Error("saving chapter", "filename", filename, "error", err)
+					} else {
+						savedMu.Lock()
+						saved[filename] = book
+						savedMu.Unlock()
 					}
 				}
 
@@ -185,42 +285,90 @@ func downloadCommandRunFunction(options *DownloadOptions) func(cmd *cobra.Comman
 
 		if options.bundle {
 			filename := saver.OutputPath(OutputDir, mangaTitle, 0, "", 0)
-			var pagesFilePaths []model.FilePath
+			book := model.Book{Manga: manga}
 			for _, chapter := range chapters {
 				if len(chapter.Pages) == 0 {
 					continue
 				}
-				pagesFilePaths = append(pagesFilePaths, model.GetSliceOfPagePathsFromPages(chapter.Pages)...)
+				book.Chapters = append(book.Chapters, chapter)
 			}
 
 			slog.Info("writing output file", "filepath", filename)
-			if err = saver.Save(filename, pagesFilePaths); err != nil {
+			if err = saver.Save(filename, book); err != nil {
 				return fmt.Errorf("writing pages to bundle pdf file: %w", err)
 			}
+			saved[filename] = book
 		}
 
 		if options.bundleVolume {
-			volumes := map[string][]model.FilePath{}
+			volumes := map[string]*model.Book{}
 			for _, chapter := range chapters {
 				if len(chapter.Pages) == 0 {
 					continue
 				}
 				filename := saver.OutputPath(OutputDir, mangaTitle, chapter.Volume, "", 0)
-				volumes[filename] = append(volumes[filename], model.GetSliceOfPagePathsFromPages(chapter.Pages)...)
+				if _, ok := volumes[filename]; !ok {
+					volumes[filename] = &model.Book{Manga: manga, Volume: chapter.Volume}
+				}
+				volumes[filename].Chapters = append(volumes[filename].Chapters, chapter)
 			}
 
-			for filename, pages := range volumes {
+			for filename, book := range volumes {
 				slog.Info("writing output file", "filepath", filename)
-				if err := saver.Save(filename, pages); err != nil {
+				if err := saver.Save(filename, *book); err != nil {
 					slog.Error("writing volume to pdf file", "filename", filename, "error", err)
+				} else {
+					saved[filename] = *book
 				}
 			}
 		}
 
+		if err := library.WriteManifests(saved); err != nil {
+			slog.Error("writing download manifests", "error", err)
+		}
 		return nil
 	}
 }
 
+// filterOwnedChapters reports the chapters of the manga the library catalog already has, and drops them with
+// --skip-owned. The download goes on without the check if the catalog can't be read.
+func filterOwnedChapters(options *DownloadOptions, mangaTitle string, chapters []model.Chapter) []model.Chapter {
+	_, catalog, err := loadCatalog(&libraryOptions{catalog: options.catalog})
+	if err != nil {
+		slog.Warn("skipping the library check", "error", err)
+		return chapters
+	}
+	allSeries := catalog.Series()
+	i := slices.IndexFunc(allSeries, func(series library.Series) bool { return strings.EqualFold(series.Title, mangaTitle) })
+	if i < 0 {
+		return chapters
+	}
+
+	var owned []float64
+	var result []model.Chapter
+	for _, chapter := range chapters {
+		// chapters without a number can't be told apart.
+		if chapter.Number > 0 && allSeries[i].Has(chapter.Volume, chapter.Number) {
+			if !slices.Contains(owned, chapter.Number) {
+				owned = append(owned, chapter.Number)
+			}
+			if options.skipOwned {
+				continue
+			}
+		}
+		result = append(result, chapter)
+	}
+	if len(owned) == 0 {
+		return result
+	}
+	if options.skipOwned {
+		slog.Info("skipping chapters already in the library", "title", mangaTitle, "chapters", library.FormatRanges(owned))
+	} else {
+		slog.Info("downloading chapters already in the library again, pass --skip-owned to skip them", "title", mangaTitle, "chapters", library.FormatRanges(owned))
+	}
+	return result
+}
+
 func parseURLOrID(s string) (uuid.UUID, error) {
 	u, err := url.Parse(s)
 	if err != nil {
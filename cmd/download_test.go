@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"github.com/radam9/manga-tools/internal/library"
+	"github.com/radam9/manga-tools/internal/model"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFilterOwnedChapters(t *testing.T) {
+	catalogPath := filepath.Join(t.TempDir(), "library.json")
+	catalog := &library.Catalog{Files: []library.File{
+		{Path: "/manga/Slam Dunk - Volume 01.cbz", Series: "Slam Dunk", Volume: 1, Chapters: []float64{1, 2}},
+	}}
+	if err := catalog.Save(catalogPath); err != nil {
+		t.Fatal(err)
+	}
+	chapters := []model.Chapter{{Number: 1, Volume: 1}, {Number: 2, Volume: 1}, {Number: 3, Volume: 1}}
+
+	tests := []struct {
+		name      string
+		title     string
+		skipOwned bool
+		expected  []float64
+	}{
+		{name: "reported", title: "Slam Dunk", expected: []float64{1, 2, 3}},
+		{name: "skipped", title: "slam dunk", skipOwned: true, expected: []float64{3}},
+		{name: "other series", title: "Vagabond", skipOwned: true, expected: []float64{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := &DownloadOptions{catalog: catalogPath, skipOwned: tt.skipOwned}
+			var got []float64
+			for _, chapter := range filterOwnedChapters(options, tt.title, chapters) {
+				got = append(got, chapter.Number)
+			}
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("expected: %v, got: %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/radam9/manga-tools/internal/library"
+	"github.com/spf13/cobra"
+	"log/slog"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+type libraryOptions struct {
+	catalog string
+}
+
+func NewLibraryCommand() *cobra.Command {
+	options := &libraryOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "library",
+		Short: "index the local library, and query what is already in it",
+		Long: `index the local library, and query what is already in it.
+The library is indexed by scanning directories into a catalog of series, volumes, chapters, languages and groups.
+The metadata is read from the ComicInfo of comic archives, the document information and outline of pdfs, and the
+package of epubs, the manifests written by download and the file names fill in what the metadata doesn't have.
+Image folders are indexed as a whole. download uses the catalog to skip the chapters already there with --skip-owned.
+
+The catalog is saved to $XDG_CONFIG_HOME/manga-tools/library.json, or to the --catalog file.`,
+		Example: `Index the library, and show what is there of a series
+	$ manga-tools library scan ~/manga
+	$ manga-tools library show "Slam Dunk"`,
+	}
+
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&options.catalog, "catalog", "", "path to the catalog file (default is $XDG_CONFIG_HOME/manga-tools/library.json)")
+
+	cmd.AddCommand(newLibraryScanCommand(options), newLibraryListCommand(options), newLibraryShowCommand(options))
+	return cmd
+}
+
+func newLibraryScanCommand(options *libraryOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "scan DIR...",
+		Short: "index the files under the directories, the changed files are read again and the removed ones dropped",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, catalog, err := loadCatalog(options)
+			if err != nil {
+				return err
+			}
+			for _, dir := range args {
+				result, err := catalog.Scan(dir)
+				if err != nil {
+					return err
+				}
+				slog.Info("scanned directory", "path", dir, "added", result.Added, "updated", result.Updated,
+					"removed", result.Removed, "unchanged", result.Unchanged)
+			}
+			return catalog.Save(path)
+		},
+	}
+}
+
+func newLibraryListCommand(options *libraryOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "list the series of the library",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, catalog, err := loadCatalog(options)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "SERIES\tVOLUMES\tCHAPTERS\tLANGUAGES\tFILES")
+			for _, series := range catalog.Series() {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n", series.Title, library.FormatVolumes(series.Volumes()),
+					library.FormatRanges(series.Chapters()), strings.Join(series.Languages(), ", "), len(series.Files))
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newLibraryShowCommand(options *libraryOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "show SERIES",
+		Short: "show the volumes, chapters and files of a series, SERIES is its title or a part of it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, catalog, err := loadCatalog(options)
+			if err != nil {
+				return err
+			}
+			series, err := catalog.FindSeries(args[0])
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "Series:\t%s\n", series.Title)
+			fmt.Fprintf(w, "Volumes:\t%s\n", library.FormatVolumes(series.Volumes()))
+			fmt.Fprintf(w, "Chapters:\t%s\n", library.FormatRanges(series.Chapters()))
+			fmt.Fprintf(w, "Languages:\t%s\n", strings.Join(series.Languages(), ", "))
+			// the groups are only known for the comic archives downloaded by manga-tools, or tagged by other tools.
+			if groups := series.Groups(); len(groups) > 0 {
+				fmt.Fprintf(w, "Groups:\t%s\n", strings.Join(groups, ", "))
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout())
+			w = tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "VOLUME\tCHAPTERS\tLANGUAGE\tFORMAT\tPAGES\tPATH")
+			for _, file := range series.Files {
+				volume := ""
+				if file.Volume > 0 {
+					volume = strconv.Itoa(file.Volume)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", volume, library.FormatRanges(file.Chapters), file.Language,
+					file.Format, file.Pages, file.Path)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func loadCatalog(options *libraryOptions) (string, *library.Catalog, error) {
+	path := options.catalog
+	if path == "" {
+		var err error
+		if path, err = library.Path(); err != nil {
+			return "", nil, err
+		}
+	}
+	catalog, err := library.Load(path)
+	if err != nil {
+		return "", nil, err
+	}
+	return path, catalog, nil
+}
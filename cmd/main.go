@@ -27,12 +27,18 @@ var versionCmd = &cobra.Command{
 }
 
 func init() {
+	rootCmd.PersistentPreRunE = loadConfig
 	rootCmd.PersistentFlags().StringVarP(&OutputDir, "output", "o", "", "path to output directory (default is current directory)")
 
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(NewDownloadCommand())
 	rootCmd.AddCommand(NewConvertCommand())
 	rootCmd.AddCommand(NewMergeCommand())
+	rootCmd.AddCommand(NewSplitCommand())
+	rootCmd.AddCommand(NewVerifyCommand())
+	rootCmd.AddCommand(NewLibraryCommand())
+	rootCmd.AddCommand(NewServeCommand())
+	rootCmd.AddCommand(NewConfigCommand())
 }
 
 func Execute() {
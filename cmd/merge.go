@@ -1,15 +1,20 @@
 package cmd
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
-	"github.com/pdfcpu/pdfcpu/pkg/api"
 	"github.com/radam9/manga-tools/internal"
+	"github.com/radam9/manga-tools/internal/archive"
 	"github.com/radam9/manga-tools/internal/format"
+	"github.com/radam9/manga-tools/internal/model"
 	"github.com/spf13/cobra"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
-	"time"
+	"unicode"
 )
 
 const (
@@ -23,6 +28,9 @@ type mergeOptions struct {
 	archive bool
 	image   bool
 	bundle  bool
+	to      string
+	name    string
+	pdf     pdfFlags
 }
 
 func NewMergeCommand() *cobra.Command {
@@ -30,29 +38,48 @@ func NewMergeCommand() *cobra.Command {
 
 	cmd := &cobra.Command{
 		Use:   "merge",
-		Short: "merges a list of pdfs into a single file",
-		Long: `merges a list of pdfs into a single file, given a list of directories and/or files.
+		Short: "merges a list of pdfs or comic archives into a single file",
+		Long: `merges a list of pdfs or comic archives (cbz, cbr, cb7, cbt) into a single file, given a list of directories and/or files.
 The items are merged in the following order:
 	1. Directories in the provided order, the files in the directory are sorted by filename.
-	2. Files in the provided order.`,
+	2. Files in the provided order.
+
+The type of the files is detected from their content. Pdfs are merged into a pdf, and comic archives into an archive
+of their format (cbz if they differ) with the pages renumbered across the inputs and their ComicInfo combined.
+Pdfs and comic archives can only be merged together with --to choosing the output format.
+
+The output is named after the longest common prefix of the input names, unless --name is passed, and merged pdfs
+have an outline entry per input labelled with its title or name, the outline of the input nested under it.`,
+		Example: `Merge the chapter archives of a volume
+	$ manga-tools merge -d ./slam-dunk/volume-1
+
+Merge pdfs and cbz archives into an epub
+	$ manga-tools merge -f chapter-1.pdf,chapter-2.cbz --to epub`,
 		Args: cobra.NoArgs,
 		RunE: mergeCommandRunFunction(options),
 	}
 
 	flags := cmd.Flags()
-	flags.StringSliceVarP(&options.dirs, mergeDirFlag, "d", nil, "comma separated list of path to directories containing pdf files to merge")
-	flags.StringSliceVarP(&options.files, mergeFilesFlag, "f", nil, "comma separated list of path to pdf files to merge")
+	flags.StringSliceVarP(&options.dirs, mergeDirFlag, "d", nil, "comma separated list of path to directories containing pdf or comic archive files to merge")
+	flags.StringSliceVarP(&options.files, mergeFilesFlag, "f", nil, "comma separated list of path to pdf or comic archive files to merge")
 	cmd.MarkFlagsOneRequired(mergeDirFlag, mergeFilesFlag)
+	flags.StringVarP(&options.name, "name", "n", "", "name of the output file without extension (default is the longest common prefix of the input names)")
+	flags.StringVar(&options.to, "to", "", fmt.Sprintf("output format %v (default is the format of the inputs)", format.Formats))
+	addPDFFlags(flags, &options.pdf)
 
 	return cmd
 }
 
 func mergeCommandRunFunction(options *mergeOptions) func(cmd *cobra.Command, args []string) error {
 	return func(cmd *cobra.Command, args []string) error {
+		pdfOptions, err := options.pdf.options()
+		if err != nil {
+			return err
+		}
 		if err := os.MkdirAll(OutputDir, 0755); err != nil {
 			return fmt.Errorf("creating output directory %q: %w", OutputDir, err)
 		}
-		var pdfs []string
+		var inputs []string
 
 		for _, dir := range options.dirs {
 			children, err := os.ReadDir(dir)
@@ -62,21 +89,207 @@ func mergeCommandRunFunction(options *mergeOptions) func(cmd *cobra.Command, arg
 			children = internal.SortDirEntry(children)
 
 			for _, child := range children {
-				pdfPath := filepath.Join(dir, child.Name())
-				if filepath.Ext(strings.ToLower(pdfPath)) != ".pdf" {
+				inputPath := filepath.Join(dir, child.Name())
+				extension := filepath.Ext(strings.ToLower(inputPath))
+				if child.IsDir() || (extension != ".pdf" && !slices.Contains(archiveFormats, extension)) {
 					continue
 				}
-				pdfs = append(pdfs, pdfPath)
+				inputs = append(inputs, inputPath)
+			}
+		}
+		inputs = append(inputs, options.files...)
+		if len(inputs) == 0 {
+			return errors.New("no pdf or comic archive files to merge")
+		}
+
+		var pdfs, archives []string
+		for _, input := range inputs {
+			isPDF, err := isPDFFile(input)
+			if err != nil {
+				return err
+			}
+			if isPDF {
+				pdfs = append(pdfs, input)
+			} else {
+				archives = append(archives, input)
 			}
 		}
-		pdfs = append(pdfs, options.files...)
 
-		_, conf := format.DefaultPDFConfig()
+		to, err := mergeOutputFormat(options.to, pdfs, archives)
+		if err != nil {
+			return err
+		}
+		saver, err := format.NewFormat(to, format.LayoutFlat, pdfOptions)
+		if err != nil {
+			return err
+		}
+		name := options.name
+		if name == "" {
+			name = mergeName(inputs)
+		}
+		outputFile := saver.OutputPath(OutputDir, name, 0, "", 0)
+		if slices.ContainsFunc(inputs, func(input string) bool { return sameFile(input, outputFile) }) {
+			return fmt.Errorf("saving %q would overwrite one of the inputs, pass --name or a different --output directory", outputFile)
+		}
+
+		if to == "pdf" && len(archives) == 0 {
+			return mergePDFs(pdfs, outputFile, pdfOptions)
+		}
+		return mergeBooks(inputs, name, saver, outputFile)
+	}
+}
+
+// isPDFFile returns true if the file is a pdf, and false if it's a comic archive.
+func isPDFFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("opening %q: %w", path, err)
+	}
+	defer f.Close()
+
+	// the pdf header can be preceded by garbage, readers look for it in the first kilobyte.
+	head := make([]byte, 1024)
+	n, err := f.Read(head)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("reading %q: %w", path, err)
+	}
+	if bytes.Contains(head[:n], []byte("%PDF-")) {
+		return true, nil
+	}
+	if _, err := archive.Detect(f); err != nil {
+		return false, fmt.Errorf("unsupported file %q, must be a pdf or a comic archive: %w", path, err)
+	}
+	return false, nil
+}
+
+// mergeOutputFormat returns the format the inputs are merged into, the format of the inputs unless one is chosen.
+func mergeOutputFormat(to string, pdfs, archives []string) (string, error) {
+	if to != "" {
+		return to, nil
+	}
+	if len(pdfs) > 0 && len(archives) > 0 {
+		return "", fmt.Errorf("can't merge pdfs with comic archives (%q and %q), choose the output format with --to", pdfs[0], archives[0])
+	}
+	if len(archives) == 0 {
+		return "pdf", nil
+	}
+
+	extension := filepath.Ext(strings.ToLower(archives[0]))
+	for _, path := range archives {
+		if filepath.Ext(strings.ToLower(path)) != extension || !slices.Contains(archiveFormats, extension) {
+			return "cbz", nil
+		}
+	}
+	return strings.TrimPrefix(extension, "."), nil
+}
+
+// mergeName returns the longest common prefix of the input names, without a number or a bracket it cuts through and the
+// separators it ends with, e.g. "Slam Dunk" for "Slam Dunk - 01.pdf" and "Slam Dunk - 02.pdf".
+func mergeName(inputs []string) string {
+	var names []string
+	for _, input := range inputs {
+		names = append(names, strings.TrimSuffix(filepath.Base(input), filepath.Ext(input)))
+	}
 
-		outputFile := filepath.Join(OutputDir, fmt.Sprintf("output_%d.pdf", time.Now().Unix()))
-		if err := api.MergeCreateFile(pdfs, outputFile, false, conf); err != nil {
-			return fmt.Errorf("merging pdf files: %w", err)
+	prefix := names[0]
+	for _, name := range names[1:] {
+		i := 0
+		for i < len(prefix) && i < len(name) && prefix[i] == name[i] {
+			i++
 		}
-		return nil
+		prefix = prefix[:i]
+	}
+	cutsNumber := slices.ContainsFunc(names, func(name string) bool {
+		return len(name) > len(prefix) && unicode.IsDigit(rune(name[len(prefix)]))
+	})
+	if cutsNumber {
+		prefix = strings.TrimRightFunc(prefix, unicode.IsDigit)
+	}
+	// a prefix cut in a multi-byte character isn't valid utf-8.
+	prefix = strings.ToValidUTF8(prefix, "")
+	prefix = trimMergeName(prefix)
+	// a bracket the inputs differ in is left open, e.g. "Slam Dunk (Vol" for "Slam Dunk (Vol 1)", it's cut off.
+	for _, brackets := range []string{"()", "[]"} {
+		if open := strings.LastIndexByte(prefix, brackets[0]); open > strings.LastIndexByte(prefix, brackets[1]) {
+			prefix = trimMergeName(prefix[:open])
+		}
+	}
+	if prefix == "" {
+		return "merged"
+	}
+	return prefix
+}
+
+// trimMergeName trims the separators the name ends with.
+func trimMergeName(name string) string {
+	return strings.TrimRightFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != ')' && r != ']'
+	})
+}
+
+func mergePDFs(pdfs []string, outputFile string, pdfOptions format.PDFOptions) error {
+	if err := format.MergePDFs(pdfs, outputFile); err != nil {
+		return fmt.Errorf("merging pdf files: %w", err)
+	}
+	if err := format.ApplyPDFOptions(outputFile, pdfOptions); err != nil {
+		return fmt.Errorf("applying pdf options to %q: %w", outputFile, err)
+	}
+	return nil
+}
+
+// mergeBooks extracts the pages of every input and saves them as a single book, a chapter per input.
+func mergeBooks(inputs []string, name string, saver format.Format, outputFile string) error {
+	tempDir, err := os.MkdirTemp("", "manga-tools-*")
+	if err != nil {
+		return fmt.Errorf("creating temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	var books []model.Book
+	for i, input := range inputs {
+		// every input is extracted into its own directory, as inputs from different directories can share a name.
+		inputDir := filepath.Join(tempDir, fmt.Sprint(i))
+		book, err := readMergeInput(inputDir, input)
+		if err != nil {
+			return fmt.Errorf("reading %q: %w", input, err)
+		}
+		books = append(books, book)
+	}
+
+	book := model.MergeBooks(books)
+	if book.Manga.Title == "" {
+		book.Manga.Title = name
+	}
+	if book.PagesCount() == 0 {
+		return errors.New("no pages to merge")
+	}
+	if err := saver.Save(outputFile, book); err != nil {
+		return fmt.Errorf("saving %q: %w", outputFile, err)
+	}
+	return nil
+}
+
+func readMergeInput(outputDir, path string) (model.Book, error) {
+	isPDF, err := isPDFFile(path)
+	if err != nil {
+		return model.Book{}, err
+	}
+	if !isPDF {
+		return format.ReadArchive(outputDir, path)
+	}
+
+	pages, err := format.ExtractPDF(outputDir, path)
+	if err != nil {
+		return model.Book{}, err
+	}
+	// the reading direction of the pdf is kept, like the one of the ComicInfo of the archives.
+	info, err := format.ReadPDFInfo(path)
+	if err != nil {
+		return model.Book{}, err
 	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return model.Book{
+		Manga:    model.Manga{Direction: info.Direction},
+		Chapters: []model.Chapter{{Title: name, Pages: model.NewPagesFromPaths(pages)}},
+	}, nil
 }
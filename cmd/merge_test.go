@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"github.com/radam9/manga-tools/internal/format"
+	"github.com/radam9/manga-tools/internal/model"
+	"github.com/radam9/manga-tools/internal/testutil"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMergeName(t *testing.T) {
+	tests := []struct {
+		inputs   []string
+		expected string
+	}{
+		{inputs: []string{"a/Slam Dunk - 01.pdf", "b/Slam Dunk - 02.pdf"}, expected: "Slam Dunk"},
+		{inputs: []string{"Slam Dunk v1 ch9.cbz", "Slam Dunk v1 ch10.cbz"}, expected: "Slam Dunk v1 ch"},
+		{inputs: []string{"Slam Dunk (Vol 1).pdf", "Slam Dunk (Vol 2).pdf"}, expected: "Slam Dunk"},
+		{inputs: []string{"Slam Dunk [Vol 1] (en).cbz", "Slam Dunk [Vol 2] (en).cbz"}, expected: "Slam Dunk"},
+		{inputs: []string{"Slam Dunk (en) 1.cbz", "Slam Dunk (en) 2.cbz"}, expected: "Slam Dunk (en)"},
+		{inputs: []string{"chapter-1.pdf"}, expected: "chapter-1"},
+		{inputs: []string{"one.pdf", "two.pdf"}, expected: "merged"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if name := mergeName(tt.inputs); name != tt.expected {
+				t.Errorf("expected: %s, got: %s", tt.expected, name)
+			}
+		})
+	}
+}
+
+func TestMergeOverwritingInput(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "Slam Dunk.cbz")
+	second := filepath.Join(dir, "Slam Dunk - 02.cbz")
+	writeTestArchive(t, first, 50)
+	writeTestArchive(t, second, 70)
+	before, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir := OutputDir
+	OutputDir = dir
+	defer func() { OutputDir = outputDir }()
+
+	cmd := NewMergeCommand()
+	cmd.SetArgs([]string{"-f", first + "," + second})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "would overwrite") {
+		t.Errorf("expected: an error about overwriting an input, got: %v", err)
+	}
+	after, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(before) != string(after) {
+		t.Errorf("expected: %q to be left as is", first)
+	}
+}
+
+func TestMergeKeepsPDFDirection(t *testing.T) {
+	dir := t.TempDir()
+	page := filepath.Join(dir, "001.png")
+	testutil.WritePNG(t, page, 10, 20)
+	var inputs []string
+	for _, name := range []string{"Slam Dunk - 01.pdf", "Slam Dunk - 02.pdf"} {
+		book := model.Book{
+			Manga:    model.Manga{Title: "Slam Dunk", Direction: model.DirectionRTL},
+			Chapters: []model.Chapter{{Number: 1, Pages: model.NewPagesFromPaths([]model.FilePath{page})}},
+		}
+		input := filepath.Join(dir, name)
+		if err := (format.PDF{}).Save(input, book); err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, input)
+	}
+
+	outputFile := filepath.Join(dir, "Slam Dunk.cbz")
+	if err := mergeBooks(inputs, "Slam Dunk", format.CBZ{}, outputFile); err != nil {
+		t.Fatal(err)
+	}
+	info, err := format.ReadArchiveComicInfo(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if direction := info.Direction(); direction != model.DirectionRTL {
+		t.Errorf("expected: %s, got: %q", model.DirectionRTL, direction)
+	}
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/radam9/manga-tools/internal/images"
+	"github.com/radam9/manga-tools/internal/model"
+	"github.com/spf13/pflag"
+	"strconv"
+	"strings"
+)
+
+const (
+	imageProfileFlag   = "profile"
+	imageResizeFlag    = "resize"
+	imageGrayscaleFlag = "grayscale"
+	imageSkipColorFlag = "skip-color"
+	imageGammaFlag     = "gamma"
+	imageContrastFlag  = "contrast"
+	imageQualityFlag   = "jpeg-quality"
+	imageFormatFlag    = "image-format"
+	imageSpreadsFlag   = "spreads"
+	imageCropFlag      = "crop-margins"
+	imageCropTolFlag   = "crop-tolerance"
+)
+
+// imageProcessingOptions are the flags of the image processing pipeline shared by the download and convert commands.
+type imageProcessingOptions struct {
+	profile     string
+	resize      string
+	grayscale   bool
+	skipColor   bool
+	gamma       float64
+	contrast    float64
+	quality     int
+	imageFormat string
+	spreads     string
+	crop        bool
+	cropTol     int
+}
+
+func addImageProcessingFlags(flags *pflag.FlagSet, options *imageProcessingOptions) {
+	var profiles []string
+	for _, profile := range images.Profiles {
+		profiles = append(profiles, profile.Name)
+	}
+
+	flags.StringVar(&options.profile, imageProfileFlag, "", fmt.Sprintf("device profile setting the image processing defaults %v", profiles))
+	flags.StringVar(&options.resize, imageResizeFlag, "", "downscale the pages to fit in WIDTHxHEIGHT, e.g. 1236x1648")
+	flags.BoolVar(&options.grayscale, imageGrayscaleFlag, false, "convert the pages to grayscale")
+	flags.BoolVar(&options.skipColor, imageSkipColorFlag, false, "keep the colour pages in colour when converting to grayscale")
+	flags.Float64Var(&options.gamma, imageGammaFlag, 0, "gamma applied to the pages, values above 1 darken the mid-tones for e-ink screens")
+	flags.Float64Var(&options.contrast, imageContrastFlag, 0, "contrast adjustment of the pages, from -1 to 1")
+	flags.IntVar(&options.quality, imageQualityFlag, 0, "re-encode the pages as jpeg with the given quality (1-100)")
+	flags.StringVar(&options.imageFormat, imageFormatFlag, "", fmt.Sprintf("transcode every page to one image format %v (default keeps the source format)", images.OutputTypes))
+	flags.BoolVar(&options.crop, imageCropFlag, false, "trim the uniform white or black borders of the pages")
+	flags.IntVar(&options.cropTol, imageCropTolFlag, images.DefaultCropTolerance, "how far (0-255) the luminance of a border pixel can be from the border colour when cropping the margins")
+	flags.StringVar(&options.spreads, imageSpreadsFlag, string(images.SpreadsKeep), fmt.Sprintf("what to do with the double-page spreads %v", images.SpreadModes))
+}
+
+// pipeline returns the image processing pipeline of the profile, overridden by the flags that are set explicitly.
+func (o imageProcessingOptions) pipeline(flags *pflag.FlagSet) (images.Pipeline, error) {
+	var pipeline images.Pipeline
+	if o.profile != "" {
+		profile, err := images.FindProfile(o.profile)
+		if err != nil {
+			return images.Pipeline{}, err
+		}
+		pipeline = profile.Pipeline
+	}
+
+	if flags.Changed(imageResizeFlag) {
+		width, height, err := parseResolution(o.resize)
+		if err != nil {
+			return images.Pipeline{}, err
+		}
+		pipeline.Width, pipeline.Height = width, height
+	}
+	if flags.Changed(imageGrayscaleFlag) {
+		pipeline.Grayscale = o.grayscale
+	}
+	if flags.Changed(imageSkipColorFlag) {
+		pipeline.SkipColorPages = o.skipColor
+	}
+	if flags.Changed(imageGammaFlag) {
+		pipeline.Gamma = o.gamma
+	}
+	if flags.Changed(imageContrastFlag) {
+		if o.contrast < -1 || o.contrast > 1 {
+			return images.Pipeline{}, fmt.Errorf("contrast %g must be between -1 and 1", o.contrast)
+		}
+		pipeline.Contrast = o.contrast
+	}
+	if flags.Changed(imageQualityFlag) {
+		if o.quality < 1 || o.quality > 100 {
+			return images.Pipeline{}, fmt.Errorf("jpeg quality %d must be between 1 and 100", o.quality)
+		}
+		pipeline.Quality = o.quality
+	}
+	if flags.Changed(imageFormatFlag) {
+		imageFormat, err := images.ParseType(o.imageFormat)
+		if err != nil {
+			return images.Pipeline{}, err
+		}
+		pipeline.Format = imageFormat
+	}
+	if flags.Changed(imageCropFlag) {
+		pipeline.CropMargins = o.crop
+	}
+	if pipeline.CropMargins {
+		if o.cropTol < 0 || o.cropTol > 255 {
+			return images.Pipeline{}, fmt.Errorf("crop tolerance %d must be between 0 and 255", o.cropTol)
+		}
+		pipeline.CropTolerance = o.cropTol
+	}
+	if flags.Changed(imageSpreadsFlag) {
+		spreads, err := images.ParseSpreads(o.spreads)
+		if err != nil {
+			return images.Pipeline{}, err
+		}
+		pipeline.Spreads = spreads
+	}
+	return pipeline, nil
+}
+
+// parseResolution parses resolutions in the WIDTHxHEIGHT form.
+func parseResolution(s string) (int, int, error) {
+	w, h, found := strings.Cut(strings.ToLower(s), "x")
+	if !found {
+		return 0, 0, fmt.Errorf("invalid resolution %q, expected WIDTHxHEIGHT", s)
+	}
+	width, err := strconv.Atoi(w)
+	if err != nil || width <= 0 {
+		return 0, 0, fmt.Errorf("invalid resolution width %q", w)
+	}
+	height, err := strconv.Atoi(h)
+	if err != nil || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid resolution height %q", h)
+	}
+	return width, height, nil
+}
+
+const directionAuto = "auto"
+
+// parseDirection parses the reading direction flag, auto returns an empty direction.
+func parseDirection(s string) (model.Direction, error) {
+	if strings.EqualFold(s, directionAuto) {
+		return "", nil
+	}
+	return model.ParseDirection(s)
+}
+
+// processPages runs the pages through the image processing pipeline, the processed pages are written to the temp dir.
+// The pages are numbered again since the split spreads become two pages.
+func processPages(pages []model.Page, tempDir string, pipeline images.Pipeline, direction model.Direction) ([]model.Page, error) {
+	pipeline.RightToLeft = direction == model.DirectionRTL
+
+	result := make([]model.Page, 0, len(pages))
+	for _, page := range pages {
+		processed, err := pipeline.Process(page.Path, tempDir)
+		if err != nil {
+			return nil, fmt.Errorf("processing page %d: %w", page.Number, err)
+		}
+		for _, p := range processed {
+			result = append(result, model.Page{Number: len(result) + 1, URL: page.URL, Path: p.Path, DoublePage: p.DoublePage})
+		}
+	}
+	return result, nil
+}
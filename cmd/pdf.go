@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/radam9/manga-tools/internal/format"
+	"github.com/spf13/pflag"
+)
+
+// pdfFlags are the flags of the pdf outputs shared by the download, convert and merge commands.
+type pdfFlags struct {
+	twoPage    bool
+	dpi        int
+	pageSize   string
+	fit        string
+	margin     float64
+	background string
+}
+
+func addPDFFlags(flags *pflag.FlagSet, options *pdfFlags) {
+	flags.BoolVar(&options.twoPage, "two-page", false, "open the pdf with two pages side by side, the cover alone")
+	flags.IntVar(&options.dpi, "pdf-dpi", format.DefaultPDFDPI, "resolution the images are printed at in the pdf, it sets the page size when no page size is given")
+	flags.StringVar(&options.pageSize, "page-size", "", "size of every pdf page, a paper size like A4, Letter or B5, or WIDTHxHEIGHT in millimeters (default sizes every page to its image)")
+	flags.StringVar(&options.fit, "page-fit", string(format.PageFitFit), fmt.Sprintf("how the images are placed on pdf pages of a fixed size %v", format.PageFits))
+	flags.Float64Var(&options.margin, "page-margin", 0, "blank margin around the pdf page content, in millimeters")
+	flags.StringVar(&options.background, "page-background", "", "colour of the pdf pages behind the images, a name like white or black, or #RRGGBB")
+}
+
+func (o pdfFlags) options() (format.PDFOptions, error) {
+	if o.dpi <= 0 {
+		return format.PDFOptions{}, fmt.Errorf("pdf dpi %d must be positive", o.dpi)
+	}
+	if o.margin < 0 {
+		return format.PDFOptions{}, fmt.Errorf("page margin %g must not be negative", o.margin)
+	}
+	pageSize, err := format.ParsePageSize(o.pageSize)
+	if err != nil {
+		return format.PDFOptions{}, err
+	}
+	fit, err := format.ParsePageFit(o.fit)
+	if err != nil {
+		return format.PDFOptions{}, err
+	}
+	background, err := format.ParseColor(o.background)
+	if err != nil {
+		return format.PDFOptions{}, err
+	}
+	return format.PDFOptions{
+		TwoPage:    o.twoPage,
+		DPI:        o.dpi,
+		PageSize:   pageSize,
+		Fit:        fit,
+		Margin:     format.MillimetersToPoints(o.margin),
+		Background: background,
+	}, nil
+}
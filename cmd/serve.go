@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/radam9/manga-tools/internal/server"
+	"github.com/spf13/cobra"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+const (
+	serveUserFlag     = "user"
+	servePasswordFlag = "password"
+)
+
+type serveOptions struct {
+	addr     string
+	opds     bool
+	user     string
+	password string
+}
+
+func NewServeCommand() *cobra.Command {
+	options := &serveOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "serve [DIR]",
+		Short: "serves the library over http to the browser and the reader apps",
+		Long: `serves the library over http to the browser and the reader apps, the library is DIR or the output directory.
+The web reader at / browses the series of the library and reads the comic archives, pdfs and image folders page by
+page, right to left, two pages at a time or as a vertical scroll for webtoons. The page every chapter was read at is
+saved, so reading picks up where it stopped.
+The pages are read out of the files without extracting them.
+
+With --opds the library is served as an OPDS 1.2 catalog at /opds for the reader apps (KOReader, Panels, Chunky, ...):
+	- the series, and their volumes, are navigation feeds.
+	- the comic archives, pdfs and epubs can be downloaded, and have a cover and a thumbnail.
+	- the comic archives, pdfs and image folders can be read page by page with OPDS page streaming (OPDS-PSE),
+	  the pages are read out of the files without extracting them.
+	- the files can be searched with OpenSearch.
+
+The library is scanned again every minute, only the changed files are read again.
+With --user and --password the server asks for them with http basic auth, use MANGA_TOOLS_SERVE_PASSWORD to keep
+the password out of the command line.`,
+		Example: `Read the library in the browser at http://localhost:8080
+	$ manga-tools serve ~/manga
+
+Serve the library to the reader apps on the local network
+	$ manga-tools serve ~/manga --opds --addr :8080`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: serveCommandRunFunction(options),
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.addr, "addr", ":8080", "address to listen on")
+	flags.BoolVar(&options.opds, "opds", false, "serve the library as an OPDS catalog at /opds")
+	flags.StringVar(&options.user, serveUserFlag, "", "user name of the http basic auth")
+	flags.StringVar(&options.password, servePasswordFlag, "", "password of the http basic auth")
+	cmd.MarkFlagsRequiredTogether(serveUserFlag, servePasswordFlag)
+
+	return cmd
+}
+
+func serveCommandRunFunction(options *serveOptions) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		dir := OutputDir
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		if dir == "" {
+			dir = "."
+		}
+		if stat, err := os.Stat(dir); err != nil {
+			return fmt.Errorf("reading library directory %q: %w", dir, err)
+		} else if !stat.IsDir() {
+			return fmt.Errorf("library %q is not a directory", dir)
+		}
+		if options.password == "" {
+			slog.Warn("serving without authentication, anyone on the network can read the library", "addr", options.addr)
+		}
+
+		progressPath, err := server.ProgressPath()
+		if err != nil {
+			return err
+		}
+		srv, err := server.New(server.Options{
+			Dir:          dir,
+			OPDS:         options.opds,
+			User:         options.user,
+			Password:     options.password,
+			ProgressPath: progressPath,
+		})
+		if err != nil {
+			return err
+		}
+		slog.Info("serving library", "path", dir, "addr", options.addr, "opds", options.opds)
+		return http.ListenAndServe(options.addr, srv.Handler())
+	}
+}
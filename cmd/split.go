@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/radam9/manga-tools/internal"
+	"github.com/radam9/manga-tools/internal/format"
+	"github.com/radam9/manga-tools/internal/images"
+	"github.com/spf13/cobra"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	splitEveryFlag   = "every"
+	splitMaxSizeFlag = "max-size"
+	splitRangesFlag  = "ranges"
+	splitOutlineFlag = "by-outline"
+	// archiveEntryOverhead is the estimated size an archive and its ComicInfo add for every page.
+	archiveEntryOverhead = 256
+	// archiveOverhead is the estimated size of an archive without its pages.
+	archiveOverhead = 2048
+)
+
+type splitOptions struct {
+	every     int
+	maxSize   string
+	ranges    string
+	byOutline bool
+}
+
+func NewSplitCommand() *cobra.Command {
+	options := &splitOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "split FILE",
+		Short: "splits a pdf or a comic archive into several files",
+		Long: `splits a pdf or a comic archive (cbz, cbr, cb7, cbt) into several files of the same format.
+The file is split with one of:
+	--every N      parts of N pages, the last part holding the remaining pages.
+	--max-size S   parts under a size like 25MB, for email and upload limits, the size of the parts is estimated.
+	--ranges R     parts of the given page ranges, like 1-40,41-90.
+	--by-outline   a part per top level entry of the pdf outline, or per chapter of a bundled archive,
+	               e.g. to split a bundled pdf back into chapters.
+
+The parts are named after the file and the part, e.g. "Slam Dunk - part 1.pdf" or "Slam Dunk - Chapter 1.cbz",
+and keep the metadata of the file.`,
+		Example: `Split a bundle back into chapters
+	$ manga-tools split "Slam Dunk.pdf" --by-outline
+
+Split a volume to send it by email
+	$ manga-tools split "Slam Dunk - volume 1.cbz" --max-size 25MB`,
+		Args: cobra.ExactArgs(1),
+		RunE: splitCommandRunFunction(options),
+	}
+
+	flags := cmd.Flags()
+	flags.IntVar(&options.every, splitEveryFlag, 0, "split into parts of the given number of pages")
+	flags.StringVar(&options.maxSize, splitMaxSizeFlag, "", "split into parts under the given size, e.g. 25MB")
+	flags.StringVar(&options.ranges, splitRangesFlag, "", "split into the given page ranges, e.g. 1-40,41-90")
+	flags.BoolVar(&options.byOutline, splitOutlineFlag, false, "split into a part per top level outline entry of pdfs, or per chapter of archives")
+	cmd.MarkFlagsOneRequired(splitEveryFlag, splitMaxSizeFlag, splitRangesFlag, splitOutlineFlag)
+	cmd.MarkFlagsMutuallyExclusive(splitEveryFlag, splitMaxSizeFlag, splitRangesFlag, splitOutlineFlag)
+
+	return cmd
+}
+
+func splitCommandRunFunction(options *splitOptions) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		if cmd.Flags().Changed(splitEveryFlag) && options.every < 1 {
+			return fmt.Errorf("--%s must be at least 1", splitEveryFlag)
+		}
+		var maxSize int64
+		if options.maxSize != "" {
+			var err error
+			if maxSize, err = internal.ParseSize(options.maxSize); err != nil {
+				return err
+			}
+		}
+		if err := os.MkdirAll(OutputDir, 0755); err != nil {
+			return fmt.Errorf("creating output directory %q: %w", OutputDir, err)
+		}
+
+		path := args[0]
+		isPDF, err := isPDFFile(path)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if isPDF {
+			return splitPDF(path, name, options, maxSize)
+		}
+		return splitArchive(path, name, options, maxSize)
+	}
+}
+
+func splitPDF(path, name string, options *splitOptions, maxSize int64) error {
+	pdf, err := format.OpenSplitPDF(path)
+	if err != nil {
+		return err
+	}
+
+	var parts []format.PageRange
+	switch {
+	case options.every > 0:
+		parts = format.EveryPages(pdf.PageCount(), options.every)
+	case maxSize > 0:
+		sizes, overhead := pdf.PageSizes()
+		parts = format.PagesBySize(sizes, overhead, maxSize)
+	case options.ranges != "":
+		parts, err = format.ParsePageRanges(options.ranges, pdf.PageCount())
+	case options.byOutline:
+		parts, err = pdf.Outline()
+		if err == nil && len(parts) == 0 {
+			err = fmt.Errorf("%q has no outline to split it by", path)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return saveParts(path, name, format.PDF{}, parts, maxSize, func(i int, outputPath string) error {
+		return pdf.Save(outputPath, parts[i])
+	})
+}
+
+func splitArchive(path, name string, options *splitOptions, maxSize int64) error {
+	tempDir, err := os.MkdirTemp("", "manga-tools-*")
+	if err != nil {
+		return fmt.Errorf("creating temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	book, err := format.ReadArchive(tempDir, path)
+	if err != nil {
+		return err
+	}
+
+	var parts []format.PageRange
+	switch {
+	case options.every > 0:
+		parts = format.EveryPages(book.PagesCount(), options.every)
+	case maxSize > 0:
+		var sizes []int64
+		for _, page := range book.PagePaths() {
+			size, _, _ := images.Stats(page)
+			sizes = append(sizes, size+archiveEntryOverhead)
+		}
+		parts = format.PagesBySize(sizes, archiveOverhead, maxSize)
+	case options.ranges != "":
+		parts, err = format.ParsePageRanges(options.ranges, book.PagesCount())
+	case options.byOutline:
+		parts = format.BookOutline(book)
+		if len(parts) < 2 {
+			err = fmt.Errorf("%q has a single chapter, it has no outline to split it by", path)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	to := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	if !slices.Contains(archiveFormats, "."+to) {
+		to = "cbz"
+	}
+	saver, err := format.NewFormat(to, format.LayoutFlat, format.PDFOptions{})
+	if err != nil {
+		return err
+	}
+	books := format.SplitBook(book, parts)
+	return saveParts(path, name, saver, parts, maxSize, func(i int, outputPath string) error {
+		return saver.Save(outputPath, books[i])
+	})
+}
+
+// saveParts saves every part named after the split file, and warns about the parts above the maximum size
+// as their size is only estimated.
+func saveParts(path, name string, saver format.Format, parts []format.PageRange, maxSize int64, save func(i int, outputPath string) error) error {
+	if len(parts) == 0 {
+		return errors.New("no pages to split")
+	}
+	seen := map[string]bool{}
+	for i, part := range parts {
+		outputPath := saver.OutputPath(OutputDir, name, 0, part.Title, 0)
+		if seen[outputPath] {
+			// outline entries can share a title.
+			outputPath = saver.OutputPath(OutputDir, name, 0, fmt.Sprintf("%s (part %d)", part.Title, i+1), 0)
+		}
+		seen[outputPath] = true
+		if sameFile(path, outputPath) {
+			return fmt.Errorf("saving %q would overwrite the split file, pass a different --output directory", outputPath)
+		}
+		if err := save(i, outputPath); err != nil {
+			return fmt.Errorf("saving pages %d-%d to %q: %w", part.From, part.Thru, outputPath, err)
+		}
+		slog.Info("saved part", "path", outputPath, "pages", fmt.Sprintf("%d-%d", part.From, part.Thru))
+
+		if stat, err := os.Stat(outputPath); err == nil && maxSize > 0 && stat.Size() > maxSize {
+			slog.Warn("part is bigger than the maximum size", "path", outputPath, "size", stat.Size())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/radam9/manga-tools/internal"
+	"github.com/radam9/manga-tools/internal/format"
+	"github.com/radam9/manga-tools/internal/model"
+	"github.com/spf13/cobra"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+type verifyOptions struct {
+	repair bool
+}
+
+func NewVerifyCommand() *cobra.Command {
+	options := &verifyOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "verify PATH...",
+		Short: "checks comic archives, pdfs and image folders for broken pages",
+		Long: `checks comic archives, pdfs and image folders for broken pages, the directories are walked recursively.
+- Comic archives (cbz, cbr, cb7, cbt): every image is decoded, and the ComicInfo is parsed.
+- Pdfs: the file is validated, and the images of the pages are decoded.
+- Image folders: every image is decoded, and the page numbering, the last number of the file names, is checked for gaps.
+
+The result is reported per file, and the command fails if any file is broken.
+With --repair the broken archives are rebuilt from the images that can still be decoded,
+the original archive is kept next to it with a .bak extension, numbered when an older backup exists.`,
+		Example: `Check a library, and rebuild the broken archives
+	$ manga-tools verify ~/manga --repair`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: verifyCommandRunFunction(options),
+		// broken files aren't a usage error.
+		SilenceUsage: true,
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&options.repair, "repair", false, "rebuild the broken archives from the images that can still be decoded")
+
+	return cmd
+}
+
+func verifyCommandRunFunction(options *verifyOptions) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		v := verifier{out: cmd.OutOrStdout(), repair: options.repair}
+		for _, path := range args {
+			stat, err := os.Stat(path)
+			if err != nil {
+				return fmt.Errorf("reading %q: %w", path, err)
+			}
+			if !stat.IsDir() {
+				v.verifyFile(path)
+				continue
+			}
+			if err := v.verifyDir(path); err != nil {
+				return err
+			}
+		}
+
+		if v.broken > 0 {
+			return fmt.Errorf("%d of %d checked items are broken", v.broken, v.checked)
+		}
+		slog.Info("verified", "items", v.checked)
+		return nil
+	}
+}
+
+type verifier struct {
+	out     io.Writer
+	repair  bool
+	checked int
+	broken  int
+}
+
+// verifyDir checks the files of the directory, its images as a single image folder, and then its sub-directories.
+func (v *verifier) verifyDir(dir string) error {
+	children, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("listing directory %q contents: %w", dir, err)
+	}
+	children = internal.SortDirEntry(children)
+
+	var pages []model.FilePath
+	var subDirs []string
+	for _, child := range children {
+		childPath := filepath.Join(dir, child.Name())
+		switch {
+		case child.IsDir():
+			subDirs = append(subDirs, childPath)
+		case internal.IsImageFile(child.Name()):
+			pages = append(pages, childPath)
+		case slices.Contains(append(slices.Clone(archiveFormats), ".pdf"), strings.ToLower(filepath.Ext(child.Name()))):
+			v.verifyFile(childPath)
+		}
+	}
+	if len(pages) > 0 {
+		v.report(dir, format.VerifyImageDir(pages), false)
+	}
+
+	for _, subDir := range subDirs {
+		if err := v.verifyDir(subDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *verifier) verifyFile(path string) {
+	isPDF, err := isPDFFile(path)
+	if err != nil {
+		v.report(path, []string{err.Error()}, false)
+		return
+	}
+	if isPDF {
+		v.report(path, format.VerifyPDF(path), false)
+		return
+	}
+	v.report(path, format.VerifyArchive(path), true)
+}
+
+// report prints the result of the item, and repairs the broken archives if asked.
+func (v *verifier) report(path string, problems []string, repairable bool) {
+	v.checked++
+	if len(problems) == 0 {
+		fmt.Fprintf(v.out, "OK     %s\n", path)
+		return
+	}
+
+	v.broken++
+	fmt.Fprintf(v.out, "BROKEN %s\n", path)
+	for _, problem := range problems {
+		fmt.Fprintf(v.out, "       - %s\n", problem)
+	}
+	if !v.repair || !repairable {
+		return
+	}
+	backupPath, err := format.RepairArchive(path)
+	if err != nil {
+		fmt.Fprintf(v.out, "       repair failed: %v\n", err)
+		return
+	}
+	v.broken--
+	fmt.Fprintf(v.out, "       repaired, the original is kept as %s\n", filepath.Base(backupPath))
+}
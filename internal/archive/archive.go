@@ -0,0 +1,114 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Type is the container format of an archive, detected from its content rather than its extension.
+type Type string
+
+const (
+	TypeZIP      Type = "zip"
+	TypeRAR      Type = "rar"
+	TypeSevenZip Type = "7z"
+	TypeTar      Type = "tar"
+)
+
+var (
+	zipMagic      = []byte("PK\x03\x04")
+	zipEmpty      = []byte("PK\x05\x06")
+	rar4Magic     = []byte("Rar!\x1a\x07\x00")
+	rar5Magic     = []byte("Rar!\x1a\x07\x01\x00")
+	sevenZipMagic = []byte("7z\xbc\xaf\x27\x1c")
+	// tarMagic is the magic of the ustar and gnu tar formats, it is located after the file name in the first header.
+	tarMagic       = []byte("ustar")
+	tarMagicOffset = 257
+)
+
+var ErrUnknownType = errors.New("unknown archive type")
+
+// Reader iterates over the files of an archive, directories are skipped.
+type Reader interface {
+	// Next advances to the next file of the archive and returns its name, it returns io.EOF at the end of the archive.
+	Next() (string, error)
+	// Read reads from the current file of the archive.
+	Read(p []byte) (int, error)
+	Close() error
+}
+
+// Writer writes files into an archive, the file is written between the call to Create and the next call to Create or Close.
+type Writer interface {
+	Create(name string) (io.Writer, error)
+	Close() error
+}
+
+// Detect returns the archive type from the magic bytes of r.
+func Detect(r io.ReaderAt) (Type, error) {
+	head := make([]byte, tarMagicOffset+len(tarMagic))
+	n, err := r.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, zipMagic), bytes.HasPrefix(head, zipEmpty):
+		return TypeZIP, nil
+	case bytes.HasPrefix(head, rar4Magic), bytes.HasPrefix(head, rar5Magic):
+		return TypeRAR, nil
+	case bytes.HasPrefix(head, sevenZipMagic):
+		return TypeSevenZip, nil
+	case len(head) > tarMagicOffset && bytes.HasPrefix(head[tarMagicOffset:], tarMagic):
+		return TypeTar, nil
+	}
+	return "", ErrUnknownType
+}
+
+// Open opens the archive at path, whatever its extension is, e.g. a .cbz that is actually a rar archive.
+func Open(path string) (Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	archiveType, err := Detect(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("detecting archive type of %q: %w", path, err)
+	}
+
+	var r Reader
+	switch archiveType {
+	case TypeZIP:
+		r, err = newZipReader(f)
+	case TypeRAR:
+		r, err = newRarReader(f)
+	case TypeSevenZip:
+		r, err = newSevenZipReader(f)
+	case TypeTar:
+		r = newTarReader(f)
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("opening %s archive %q: %w", archiveType, path, err)
+	}
+	return r, nil
+}
+
+// NewWriter creates a writer of the given archive type, rar archives can't be written.
+func NewWriter(archiveType Type, w io.WriteSeeker) (Writer, error) {
+	switch archiveType {
+	case TypeZIP:
+		return zip.NewWriter(w), nil
+	case TypeSevenZip:
+		return newSevenZipWriter(w)
+	case TypeTar:
+		return newTarWriter(w), nil
+	}
+	return nil, fmt.Errorf("writing %s archives is not supported", archiveType)
+}
@@ -0,0 +1,213 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected Type
+		err      error
+	}{
+		{name: "zip", data: []byte("PK\x03\x04rest"), expected: TypeZIP},
+		{name: "empty zip", data: []byte("PK\x05\x06"), expected: TypeZIP},
+		{name: "rar v4", data: []byte("Rar!\x1a\x07\x00rest"), expected: TypeRAR},
+		{name: "rar v5", data: []byte("Rar!\x1a\x07\x01\x00rest"), expected: TypeRAR},
+		{name: "unknown", data: []byte("%PDF-1.7"), err: ErrUnknownType},
+		{name: "empty", data: nil, err: ErrUnknownType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Detect(bytes.NewReader(tt.data))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error: %v, got: %v", tt.err, err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected: %s, got: %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestOpenMislabelledZip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chapter.cbr")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	if _, err := w.Create("dir/"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"dir/0001.jpg", "0002.jpg"} {
+		entry, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(entry, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var got []string
+	for {
+		name, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != name {
+			t.Errorf("expected content: %s, got: %s", name, data)
+		}
+		got = append(got, name)
+	}
+	if len(got) != 2 || got[0] != "dir/0001.jpg" || got[1] != "0002.jpg" {
+		t.Errorf("expected the 2 files without the directory, got: %v", got)
+	}
+}
+
+// the rar fixtures hold a directory and two stored files, "dir/0001.jpg" and "0002.jpg", with their name as content.
+// They're written by hand with the files stored uncompressed, as there's no free tool making rar archives.
+func TestOpenRar(t *testing.T) {
+	for _, name := range []string{"stored-v4.rar", "stored-v5.rar"} {
+		t.Run(name, func(t *testing.T) {
+			src := filepath.Join("testdata", name)
+			f, err := os.Open(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			archiveType, err := Detect(f)
+			f.Close()
+			if err != nil || archiveType != TypeRAR {
+				t.Fatalf("expected: %s, got: %s (%v)", TypeRAR, archiveType, err)
+			}
+
+			// rar archives are read whatever their extension is.
+			data, err := os.ReadFile(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "chapter.cbz")
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			r, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			var got []string
+			for {
+				name, err := r.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != name {
+					t.Errorf("expected content: %s, got: %s", name, data)
+				}
+				got = append(got, name)
+			}
+			if len(got) != 2 || got[0] != "dir/0001.jpg" || got[1] != "0002.jpg" {
+				t.Errorf("expected the 2 files without the directory, got: %v", got)
+			}
+		})
+	}
+}
+
+func TestWriteAndOpen(t *testing.T) {
+	for _, archiveType := range []Type{TypeZIP, TypeSevenZip, TypeTar} {
+		t.Run(string(archiveType), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "chapter")
+			f, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w, err := NewWriter(archiveType, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// empty entries have no data stream in 7z archives.
+			expected := map[string]string{"0000.jpg": "first page", "0001.jpg": "second page", "ComicInfo.xml": "<ComicInfo/>", "empty.txt": ""}
+			for _, name := range []string{"ComicInfo.xml", "0000.jpg", "empty.txt", "0001.jpg"} {
+				entry, err := w.Create(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := io.WriteString(entry, expected[name]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+
+			r, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			count := 0
+			for {
+				name, err := r.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != expected[name] {
+					t.Errorf("expected %s content: %s, got: %s", name, expected[name], data)
+				}
+				count++
+			}
+			if count != len(expected) {
+				t.Errorf("expected: %d files, got: %d", len(expected), count)
+			}
+		})
+	}
+
+	t.Run("rar", func(t *testing.T) {
+		if _, err := NewWriter(TypeRAR, nil); err == nil {
+			t.Errorf("expected an error when writing rar archives")
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package archive
+
+import (
+	"github.com/nwaples/rardecode/v2"
+	"os"
+)
+
+// rarReader reads rar v4 and v5 archives.
+type rarReader struct {
+	f *os.File
+	r *rardecode.Reader
+}
+
+func newRarReader(f *os.File) (*rarReader, error) {
+	r, err := rardecode.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	return &rarReader{f: f, r: r}, nil
+}
+
+func (r *rarReader) Next() (string, error) {
+	for {
+		header, err := r.r.Next()
+		if err != nil {
+			return "", err
+		}
+		if header.IsDir {
+			continue
+		}
+		return header.Name, nil
+	}
+}
+
+func (r *rarReader) Read(p []byte) (int, error) {
+	return r.r.Read(p)
+}
+
+func (r *rarReader) Close() error {
+	return r.f.Close()
+}
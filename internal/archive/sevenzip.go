@@ -0,0 +1,63 @@
+package archive
+
+import (
+	"github.com/bodgit/sevenzip"
+	"io"
+	"os"
+)
+
+type sevenZipReader struct {
+	f       *os.File
+	r       *sevenzip.Reader
+	index   int
+	current io.ReadCloser
+}
+
+func newSevenZipReader(f *os.File) (*sevenZipReader, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	r, err := sevenzip.NewReader(f, stat.Size())
+	if err != nil {
+		return nil, err
+	}
+	return &sevenZipReader{f: f, r: r}, nil
+}
+
+func (s *sevenZipReader) Next() (string, error) {
+	if s.current != nil {
+		s.current.Close()
+		s.current = nil
+	}
+
+	for s.index < len(s.r.File) {
+		file := s.r.File[s.index]
+		s.index++
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		current, err := file.Open()
+		if err != nil {
+			return "", err
+		}
+		s.current = current
+		return file.Name, nil
+	}
+	return "", io.EOF
+}
+
+func (s *sevenZipReader) Read(p []byte) (int, error) {
+	if s.current == nil {
+		return 0, io.EOF
+	}
+	return s.current.Read(p)
+}
+
+func (s *sevenZipReader) Close() error {
+	if s.current != nil {
+		s.current.Close()
+	}
+	return s.f.Close()
+}
@@ -0,0 +1,269 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"unicode/utf16"
+)
+
+// 7z header property ids, see 7zFormat.txt of the 7-Zip sources.
+const (
+	sevenZipEnd              = 0x00
+	sevenZipHeader           = 0x01
+	sevenZipMainStreamsInfo  = 0x04
+	sevenZipFilesInfo        = 0x05
+	sevenZipPackInfo         = 0x06
+	sevenZipUnPackInfo       = 0x07
+	sevenZipSubStreamsInfo   = 0x08
+	sevenZipSize             = 0x09
+	sevenZipCRC              = 0x0a
+	sevenZipFolder           = 0x0b
+	sevenZipCodersUnPackSize = 0x0c
+	sevenZipNumUnPackStream  = 0x0d
+	sevenZipEmptyStream      = 0x0e
+	sevenZipEmptyFile        = 0x0f
+	sevenZipName             = 0x11
+)
+
+const sevenZipSignatureHeaderSize = 32
+
+// sevenZipWriter writes a 7z archive where every file is stored uncompressed in its own folder.
+// Manga pages are already compressed images, so compressing them again only costs time.
+// Empty files have no stream, they are only listed in the files info.
+type sevenZipWriter struct {
+	w       io.WriteSeeker
+	files   []sevenZipFile
+	current *sevenZipFile
+	hash    hash.Hash32
+}
+
+type sevenZipFile struct {
+	name string
+	size uint64
+	crc  uint32
+}
+
+func newSevenZipWriter(w io.WriteSeeker) (*sevenZipWriter, error) {
+	// the signature header is written on close, once the position and size of the header are known.
+	if _, err := w.Write(make([]byte, sevenZipSignatureHeaderSize)); err != nil {
+		return nil, err
+	}
+	return &sevenZipWriter{w: w, hash: crc32.NewIEEE()}, nil
+}
+
+func (s *sevenZipWriter) Create(name string) (io.Writer, error) {
+	if err := s.closeCurrent(); err != nil {
+		return nil, err
+	}
+	s.current = &sevenZipFile{name: name}
+	s.hash.Reset()
+	return s, nil
+}
+
+func (s *sevenZipWriter) Write(p []byte) (int, error) {
+	if s.current == nil {
+		return 0, errors.New("7z: write before create")
+	}
+	n, err := s.w.Write(p)
+	s.hash.Write(p[:n])
+	s.current.size += uint64(n)
+	return n, err
+}
+
+func (s *sevenZipWriter) closeCurrent() error {
+	if s.current == nil {
+		return nil
+	}
+	s.current.crc = s.hash.Sum32()
+	s.files = append(s.files, *s.current)
+	s.current = nil
+	return nil
+}
+
+func (s *sevenZipWriter) Close() error {
+	if err := s.closeCurrent(); err != nil {
+		return err
+	}
+
+	var packedSize uint64
+	for _, file := range s.streams() {
+		packedSize += file.size
+	}
+
+	header := s.header()
+	if _, err := s.w.Write(header); err != nil {
+		return err
+	}
+
+	startHeader := make([]byte, 20)
+	binary.LittleEndian.PutUint64(startHeader[0:], packedSize)
+	binary.LittleEndian.PutUint64(startHeader[8:], uint64(len(header)))
+	binary.LittleEndian.PutUint32(startHeader[16:], crc32.ChecksumIEEE(header))
+
+	signatureHeader := bytes.NewBuffer(nil)
+	signatureHeader.Write(sevenZipMagic)
+	signatureHeader.Write([]byte{0, 4})
+	binary.Write(signatureHeader, binary.LittleEndian, crc32.ChecksumIEEE(startHeader))
+	signatureHeader.Write(startHeader)
+
+	if _, err := s.w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := s.w.Write(signatureHeader.Bytes()); err != nil {
+		return err
+	}
+	_, err := s.w.Seek(0, io.SeekEnd)
+	return err
+}
+
+// streams returns the files that have data, empty files have no stream.
+func (s *sevenZipWriter) streams() []sevenZipFile {
+	var streams []sevenZipFile
+	for _, file := range s.files {
+		if file.size > 0 {
+			streams = append(streams, file)
+		}
+	}
+	return streams
+}
+
+func (s *sevenZipWriter) header() []byte {
+	b := &sevenZipBuffer{}
+	b.WriteByte(sevenZipHeader)
+	if streams := s.streams(); len(streams) > 0 {
+		b.writeStreamsInfo(streams)
+	}
+	b.writeFilesInfo(s.files)
+	b.WriteByte(sevenZipEnd)
+	return b.Bytes()
+}
+
+func (b *sevenZipBuffer) writeStreamsInfo(streams []sevenZipFile) {
+	b.WriteByte(sevenZipMainStreamsInfo)
+	b.WriteByte(sevenZipPackInfo)
+	b.writeNumber(0)
+	b.writeNumber(uint64(len(streams)))
+	b.WriteByte(sevenZipSize)
+	for _, file := range streams {
+		b.writeNumber(file.size)
+	}
+	b.WriteByte(sevenZipEnd)
+
+	b.WriteByte(sevenZipUnPackInfo)
+	b.WriteByte(sevenZipFolder)
+	b.writeNumber(uint64(len(streams)))
+	b.WriteByte(0) // not external
+	for range streams {
+		b.writeNumber(1)  // one coder
+		b.WriteByte(0x01) // simple coder with a one byte id
+		b.WriteByte(0x00) // copy method id
+	}
+	b.WriteByte(sevenZipCodersUnPackSize)
+	for _, file := range streams {
+		b.writeNumber(file.size)
+	}
+	b.WriteByte(sevenZipCRC)
+	b.WriteByte(1) // all crcs are defined
+	for _, file := range streams {
+		binary.Write(b, binary.LittleEndian, file.crc)
+	}
+	b.WriteByte(sevenZipEnd)
+
+	// one file per folder is the default, but some readers only map the files to the folders using the sub streams.
+	b.WriteByte(sevenZipSubStreamsInfo)
+	b.WriteByte(sevenZipNumUnPackStream)
+	for range streams {
+		b.writeNumber(1)
+	}
+	b.WriteByte(sevenZipEnd)
+	b.WriteByte(sevenZipEnd)
+}
+
+func (b *sevenZipBuffer) writeFilesInfo(files []sevenZipFile) {
+	b.WriteByte(sevenZipFilesInfo)
+	b.writeNumber(uint64(len(files)))
+
+	var emptyStreams []bool
+	for _, file := range files {
+		emptyStreams = append(emptyStreams, file.size == 0)
+	}
+	if empty := bitCount(emptyStreams); empty > 0 {
+		b.WriteByte(sevenZipEmptyStream)
+		b.writeProperty(bitVector(emptyStreams))
+		// the empty streams are files rather than directories.
+		emptyFiles := make([]bool, empty)
+		for i := range emptyFiles {
+			emptyFiles[i] = true
+		}
+		b.WriteByte(sevenZipEmptyFile)
+		b.writeProperty(bitVector(emptyFiles))
+	}
+
+	names := &sevenZipBuffer{}
+	names.WriteByte(0) // not external
+	for _, file := range files {
+		for _, r := range utf16.Encode([]rune(file.name)) {
+			binary.Write(names, binary.LittleEndian, r)
+		}
+		binary.Write(names, binary.LittleEndian, uint16(0))
+	}
+	b.WriteByte(sevenZipName)
+	b.writeProperty(names.Bytes())
+	b.WriteByte(sevenZipEnd)
+}
+
+type sevenZipBuffer struct {
+	bytes.Buffer
+}
+
+// writeNumber writes a 7z variable length number, the count of the leading one bits of the first byte
+// is the count of the extra little endian bytes that follow it.
+func (b *sevenZipBuffer) writeNumber(value uint64) {
+	var first byte
+	var mask byte = 0x80
+	i := 0
+	for ; i < 8; i++ {
+		if value < uint64(1)<<(7*(i+1)) {
+			first |= byte(value >> (8 * i))
+			break
+		}
+		first |= mask
+		mask >>= 1
+	}
+	b.WriteByte(first)
+	for ; i > 0; i-- {
+		b.WriteByte(byte(value))
+		value >>= 8
+	}
+}
+
+// writeProperty writes the size of a files info property followed by its data.
+func (b *sevenZipBuffer) writeProperty(data []byte) {
+	b.writeNumber(uint64(len(data)))
+	b.Write(data)
+}
+
+// bitVector packs bits the way 7z does, the most significant bit of each byte first.
+func bitVector(bits []bool) []byte {
+	vector := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit {
+			vector[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return vector
+}
+
+func bitCount(bits []bool) int {
+	count := 0
+	for _, bit := range bits {
+		if bit {
+			count++
+		}
+	}
+	return count
+}
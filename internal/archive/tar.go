@@ -0,0 +1,78 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+)
+
+type tarReader struct {
+	f *os.File
+	r *tar.Reader
+}
+
+func newTarReader(f *os.File) *tarReader {
+	return &tarReader{f: f, r: tar.NewReader(f)}
+}
+
+func (t *tarReader) Next() (string, error) {
+	for {
+		header, err := t.r.Next()
+		if err != nil {
+			return "", err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		return header.Name, nil
+	}
+}
+
+func (t *tarReader) Read(p []byte) (int, error) {
+	return t.r.Read(p)
+}
+
+func (t *tarReader) Close() error {
+	return t.f.Close()
+}
+
+// tarWriter buffers each file in memory, as the tar header holds the size of the file.
+type tarWriter struct {
+	w       *tar.Writer
+	name    string
+	current *bytes.Buffer
+}
+
+func newTarWriter(w io.Writer) *tarWriter {
+	return &tarWriter{w: tar.NewWriter(w)}
+}
+
+func (t *tarWriter) Create(name string) (io.Writer, error) {
+	if err := t.flush(); err != nil {
+		return nil, err
+	}
+	t.name = name
+	t.current = &bytes.Buffer{}
+	return t.current, nil
+}
+
+func (t *tarWriter) flush() error {
+	if t.current == nil {
+		return nil
+	}
+	header := &tar.Header{Name: t.name, Mode: 0644, Size: int64(t.current.Len()), Typeflag: tar.TypeReg}
+	if err := t.w.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := t.w.Write(t.current.Bytes())
+	t.current = nil
+	return err
+}
+
+func (t *tarWriter) Close() error {
+	if err := t.flush(); err != nil {
+		return err
+	}
+	return t.w.Close()
+}
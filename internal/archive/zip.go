@@ -0,0 +1,63 @@
+package archive
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+)
+
+type zipReader struct {
+	f       *os.File
+	r       *zip.Reader
+	index   int
+	current io.ReadCloser
+}
+
+func newZipReader(f *os.File) (*zipReader, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	r, err := zip.NewReader(f, stat.Size())
+	if err != nil {
+		return nil, err
+	}
+	return &zipReader{f: f, r: r}, nil
+}
+
+func (z *zipReader) Next() (string, error) {
+	if z.current != nil {
+		z.current.Close()
+		z.current = nil
+	}
+
+	for z.index < len(z.r.File) {
+		file := z.r.File[z.index]
+		z.index++
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		current, err := file.Open()
+		if err != nil {
+			return "", err
+		}
+		z.current = current
+		return file.Name, nil
+	}
+	return "", io.EOF
+}
+
+func (z *zipReader) Read(p []byte) (int, error) {
+	if z.current == nil {
+		return 0, io.EOF
+	}
+	return z.current.Read(p)
+}
+
+func (z *zipReader) Close() error {
+	if z.current != nil {
+		z.current.Close()
+	}
+	return z.f.Close()
+}
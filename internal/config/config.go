@@ -0,0 +1,212 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// EnvPrefix prefixes the environment variables of the flags, e.g. MANGA_TOOLS_OUTPUT or MANGA_TOOLS_DOWNLOAD_LANGUAGE.
+	EnvPrefix = "MANGA_TOOLS"
+	dirName   = "manga-tools"
+	fileName  = "config.yaml"
+	// mutuallyExclusiveAnnotation is the flag annotation cobra stores the mutually exclusive flag groups in.
+	mutuallyExclusiveAnnotation = "cobra_annotation_mutually_exclusive"
+)
+
+// File is the content of the config file. The top level keys are flag values for every command, and the command
+// names are sections with flag values for that command only:
+//
+//	output: ~/manga
+//	download:
+//	  language: en
+//	  cbz: true
+type File map[string]any
+
+// Path returns the path of the config file, `$XDG_CONFIG_HOME/manga-tools/config.yaml`.
+func Path() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("finding config directory: %w", err)
+	}
+	return filepath.Join(dir, dirName, fileName), nil
+}
+
+// Load reads the config file, a missing file is an empty config.
+func Load(path string) (File, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return File{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading config file %q: %w", path, err)
+	}
+
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing config file %q: %w", path, err)
+	}
+	file := File{}
+	for key, value := range raw {
+		file[key] = normalize(value)
+	}
+	return file, nil
+}
+
+// normalize turns the nested maps yaml decodes into string keyed maps.
+func normalize(value any) any {
+	switch v := value.(type) {
+	case map[any]any:
+		result := map[string]any{}
+		for key, value := range v {
+			result[fmt.Sprint(key)] = normalize(value)
+		}
+		return result
+	case []any:
+		for i := range v {
+			v[i] = normalize(v[i])
+		}
+	}
+	return value
+}
+
+func (f File) Save(path string) error {
+	data, err := yaml.Marshal(map[string]any(f))
+	if err != nil {
+		return fmt.Errorf("encoding config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating config directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing config file %q: %w", path, err)
+	}
+	return nil
+}
+
+// Set sets the value of a key, `flag` for every command or `command.flag` for a single command.
+// Booleans and numbers are stored as such, so the file stays readable.
+func (f File) Set(key, value string) error {
+	command, flag, found := strings.Cut(key, ".")
+	if !found {
+		f[key] = parseValue(value)
+		return nil
+	}
+	if command == "" || flag == "" {
+		return fmt.Errorf("invalid key %q, must be FLAG or COMMAND.FLAG", key)
+	}
+
+	section, ok := f[command].(map[string]any)
+	if !ok {
+		section = map[string]any{}
+		f[command] = section
+	}
+	section[flag] = parseValue(value)
+	return nil
+}
+
+func parseValue(value string) any {
+	if b, err := strconv.ParseBool(value); err == nil {
+		return b
+	}
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f
+	}
+	return value
+}
+
+// Apply sets the flags that were not passed explicitly from, in order of precedence: the MANGA_TOOLS_<COMMAND>_<FLAG>
+// and MANGA_TOOLS_<FLAG> environment variables, the command section and the top level of the config file.
+// A flag is left alone if another flag of its mutually exclusive group is set, so `--pdf` isn't combined with `cbz: true`.
+func Apply(flags *pflag.FlagSet, command string, file File, lookupEnv func(string) (string, bool)) error {
+	section, _ := file[command].(map[string]any)
+	sources := []func(flag string) (string, bool){
+		func(flag string) (string, bool) { return lookupEnv(envName(command, flag)) },
+		func(flag string) (string, bool) { return lookupEnv(envName("", flag)) },
+		func(flag string) (string, bool) { return fileValue(section, flag) },
+		func(flag string) (string, bool) { return fileValue(file, flag) },
+	}
+
+	for _, source := range sources {
+		var err error
+		flags.VisitAll(func(flag *pflag.Flag) {
+			if err != nil || flag.Changed || flag.Name == "help" || exclusiveFlagSet(flags, flag) {
+				return
+			}
+			value, found := source(flag.Name)
+			if !found {
+				return
+			}
+			if setErr := flags.Set(flag.Name, value); setErr != nil {
+				err = fmt.Errorf("setting %q from the config: %w", flag.Name, setErr)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func envName(command, flag string) string {
+	parts := []string{EnvPrefix}
+	if command != "" {
+		parts = append(parts, command)
+	}
+	parts = append(parts, flag)
+	return strings.ToUpper(strings.ReplaceAll(strings.Join(parts, "_"), "-", "_"))
+}
+
+func fileValue(values map[string]any, flag string) (string, bool) {
+	value, found := values[flag]
+	if !found {
+		return "", false
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		// a command section named like a flag.
+		return "", false
+	case []any:
+		var items []string
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ","), true
+	case string:
+		return expandHome(v), true
+	default:
+		return fmt.Sprint(v), true
+	}
+}
+
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
+
+// exclusiveFlagSet returns true if another flag of one of the mutually exclusive groups of the flag is set.
+func exclusiveFlagSet(flags *pflag.FlagSet, flag *pflag.Flag) bool {
+	for _, group := range flag.Annotations[mutuallyExclusiveAnnotation] {
+		for _, name := range strings.Split(group, " ") {
+			if other := flags.Lookup(name); other != nil && other.Changed && name != flag.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
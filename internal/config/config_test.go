@@ -0,0 +1,93 @@
+package config
+
+import (
+	"github.com/spf13/pflag"
+	"path/filepath"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	newFlags := func(args ...string) *pflag.FlagSet {
+		flags := pflag.NewFlagSet("download", pflag.ContinueOnError)
+		flags.StringP("language", "l", "", "")
+		flags.StringP("output", "o", "", "")
+		flags.Bool("cbz", false, "")
+		flags.Bool("pdf", false, "")
+		flags.StringSlice("files", nil, "")
+		for _, name := range []string{"cbz", "pdf"} {
+			if err := flags.SetAnnotation(name, mutuallyExclusiveAnnotation, []string{"cbz pdf"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := flags.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		return flags
+	}
+
+	file := File{
+		"output":   "/manga",
+		"language": "fr",
+		"files":    []any{"a.pdf", "b.pdf"},
+		"download": map[string]any{"language": "en", "cbz": true},
+	}
+	env := map[string]string{}
+	lookupEnv := func(key string) (string, bool) {
+		value, found := env[key]
+		return value, found
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		flag     string
+		expected string
+	}{
+		{name: "top level value", flag: "output", expected: "/manga"},
+		{name: "command section wins over top level", flag: "language", expected: "en"},
+		{name: "list value", flag: "files", expected: "[a.pdf,b.pdf]"},
+		{name: "environment wins over the file", env: map[string]string{"MANGA_TOOLS_LANGUAGE": "de"}, flag: "language", expected: "de"},
+		{name: "command environment wins", env: map[string]string{"MANGA_TOOLS_LANGUAGE": "de", "MANGA_TOOLS_DOWNLOAD_LANGUAGE": "it"}, flag: "language", expected: "it"},
+		{name: "explicit flag wins", args: []string{"-l", "es"}, env: map[string]string{"MANGA_TOOLS_LANGUAGE": "de"}, flag: "language", expected: "es"},
+		{name: "mutually exclusive flag is not set", args: []string{"--pdf"}, flag: "cbz", expected: "false"},
+		{name: "mutually exclusive flag is set alone", flag: "cbz", expected: "true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env = tt.env
+			flags := newFlags(tt.args...)
+			if err := Apply(flags, "download", file, lookupEnv); err != nil {
+				t.Fatal(err)
+			}
+			if got := flags.Lookup(tt.flag).Value.String(); got != tt.expected {
+				t.Errorf("expected: %s, got: %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSetAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manga-tools", "config.yaml")
+	file, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range map[string]string{"output": "/manga", "download.cbz": "true", "download.language": "en"} {
+		if err := file.Set(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := file.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	section, ok := got["download"].(map[string]any)
+	if !ok || section["cbz"] != true || section["language"] != "en" || got["output"] != "/manga" {
+		t.Errorf("unexpected config: %v", got)
+	}
+}
@@ -1,47 +1,94 @@
 package format
 
 import (
-	"archive/zip"
 	"errors"
 	"fmt"
+	"github.com/maruel/natural"
+	"github.com/radam9/manga-tools/internal"
+	"github.com/radam9/manga-tools/internal/archive"
+	"github.com/radam9/manga-tools/internal/images"
 	"github.com/radam9/manga-tools/internal/model"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 )
 
-type CBR struct{}
+type CBR struct {
+	Layout Layout
+}
 
-func (c CBR) Save(filePath string, pages []model.FilePath) error {
-	return saveAsCBArchive(filePath, pages)
+// Save writes the CBR as a zip archive, as rar archives can only be created by the proprietary rar tools.
+func (c CBR) Save(filePath string, book model.Book) error {
+	return saveAsCBArchive(filePath, book, archive.TypeZIP)
 }
 
 func (c CBR) OutputPath(outputDir string, mangaTitle string, volume int, chapterTitle string, chapter float64) string {
-	filePath := getOutputFilePath(outputDir, mangaTitle, volume, chapter, chapterTitle)
+	filePath := getOutputFilePath(outputDir, c.Layout, mangaTitle, volume, chapter, chapterTitle)
 	return filePath + ".cbr"
 }
 
-type CBZ struct{}
+type CBZ struct {
+	Layout Layout
+}
 
-func (c CBZ) Save(filePath string, pages []model.FilePath) error {
-	return saveAsCBArchive(filePath, pages)
+func (c CBZ) Save(filePath string, book model.Book) error {
+	return saveAsCBArchive(filePath, book, archive.TypeZIP)
 }
 
 func (c CBZ) OutputPath(outputDir string, mangaTitle string, volume int, chapterTitle string, chapter float64) string {
-	filePath := getOutputFilePath(outputDir, mangaTitle, volume, chapter, chapterTitle)
+	filePath := getOutputFilePath(outputDir, c.Layout, mangaTitle, volume, chapter, chapterTitle)
 	return filePath + ".cbz"
 }
 
-func saveAsCBArchive(filePath string, pages []model.FilePath) error {
+type CB7 struct {
+	Layout Layout
+}
+
+func (c CB7) Save(filePath string, book model.Book) error {
+	return saveAsCBArchive(filePath, book, archive.TypeSevenZip)
+}
+
+func (c CB7) OutputPath(outputDir string, mangaTitle string, volume int, chapterTitle string, chapter float64) string {
+	filePath := getOutputFilePath(outputDir, c.Layout, mangaTitle, volume, chapter, chapterTitle)
+	return filePath + ".cb7"
+}
+
+type CBT struct {
+	Layout Layout
+}
+
+func (c CBT) Save(filePath string, book model.Book) error {
+	return saveAsCBArchive(filePath, book, archive.TypeTar)
+}
+
+func (c CBT) OutputPath(outputDir string, mangaTitle string, volume int, chapterTitle string, chapter float64) string {
+	filePath := getOutputFilePath(outputDir, c.Layout, mangaTitle, volume, chapter, chapterTitle)
+	return filePath + ".cbt"
+}
+
+func saveAsCBArchive(filePath string, book model.Book, archiveType archive.Type) error {
+	pages := book.PagePaths()
 	if len(pages) == 0 {
 		return errors.New("no files to pack")
 	}
+	if err := createParentDir(filePath); err != nil {
+		return err
+	}
 	buff, err := os.Create(filePath)
 	if err != nil {
 		return err
 	}
 	defer buff.Close()
-	w := zip.NewWriter(buff)
+	w, err := archive.NewWriter(archiveType, buff)
+	if err != nil {
+		return err
+	}
+
+	if err := writeComicInfoToArchive(w, NewComicInfo(book)); err != nil {
+		return err
+	}
 
 	for i, page := range pages {
 		if err := writeFileToArchive(w, i, page); err != nil {
@@ -51,55 +98,123 @@ func saveAsCBArchive(filePath string, pages []model.FilePath) error {
 	return w.Close()
 }
 
-func writeFileToArchive(w *zip.Writer, pageNum int, pagePath model.FilePath) error {
-	f, err := w.Create(fmt.Sprintf("%04d.jpg", pageNum))
+func writeComicInfoToArchive(w archive.Writer, info ComicInfo) error {
+	data, err := info.Marshal()
 	if err != nil {
 		return err
 	}
 
-	pageData, err := os.Open(pagePath)
+	f, err := w.Create(comicInfoFileName)
 	if err != nil {
 		return err
 	}
+	_, err = f.Write(data)
+	return err
+}
 
-	if _, err := io.Copy(f, pageData); err != nil {
+func writeFileToArchive(w archive.Writer, pageNum int, pagePath model.FilePath) error {
+	imageType, err := images.Detect(pagePath)
+	if err != nil {
 		return err
 	}
-	return os.Remove(pagePath)
+
+	f, err := w.Create(fmt.Sprintf("%04d%s", pageNum, imageType.Extension()))
+	if err != nil {
+		return err
+	}
+
+	pageData, err := os.Open(pagePath)
+	if err != nil {
+		return err
+	}
+	defer pageData.Close()
+
+	_, err = io.Copy(f, pageData)
+	return err
 }
 
-func ExtractArchive(tempDir, archiveDir, archiveName string) ([]model.FilePath, error) {
-	archivePath := filepath.Join(archiveDir, archiveName)
-	r, err := zip.OpenReader(archivePath)
+// ExtractArchive extracts the images of the archive into the output dir, and returns their paths in natural order.
+// The archive type is detected from its content, so rar and zip archives are read whatever their extension is.
+// The pages are named by their position, so every archive needs its own output dir.
+func ExtractArchive(outputDir, archivePath string) ([]model.FilePath, error) {
+	r, err := archive.Open(archivePath)
 	if err != nil {
-		return nil, fmt.Errorf("opening archive %q: %w", archivePath, err)
+		return nil, err
 	}
 	defer r.Close()
 
-	var imagePaths []string
-	outputDir := filepath.Join(tempDir, archiveName)
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return nil, fmt.Errorf("creating output directory %q: %w", outputDir, err)
 	}
 
-	for _, file := range r.File {
-		dstFile, err := os.Create(filepath.Join(outputDir, file.Name))
+	type extractedImage struct {
+		name string
+		path model.FilePath
+	}
+	var images []extractedImage
+	for {
+		name, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
 		if err != nil {
-			return nil, fmt.Errorf("opening temp file %q: %w", file.Name, err)
+			return nil, fmt.Errorf("reading archive %q: %w", archivePath, err)
+		}
+		if !internal.IsImageFile(name) {
+			continue
 		}
 
-		fileInArchive, err := file.Open()
-		if err != nil {
-			return nil, fmt.Errorf("opening archive file %q: %w", file.Name, err)
+		// the archive entries can be nested in directories, they are flattened to avoid writing outside the output dir.
+		dstPath := filepath.Join(outputDir, fmt.Sprintf("%04d%s", len(images), filepath.Ext(name)))
+		if err := extractFile(r, dstPath); err != nil {
+			return nil, fmt.Errorf("extracting file %q: %w", name, err)
 		}
+		images = append(images, extractedImage{name: name, path: dstPath})
+	}
 
-		if _, err := io.Copy(dstFile, fileInArchive); err != nil {
-			return nil, fmt.Errorf("copying file %q: %w", file.Name, err)
+	slices.SortStableFunc(images, func(a, b extractedImage) int {
+		if natural.Less(a.name, b.name) {
+			return -1
+		} else if natural.Less(b.name, a.name) {
+			return 1
 		}
+		return 0
+	})
 
-		imagePaths = append(imagePaths, dstFile.Name())
-		dstFile.Close()
-		fileInArchive.Close()
+	imagePaths := make([]model.FilePath, 0, len(images))
+	for _, image := range images {
+		imagePaths = append(imagePaths, image.path)
 	}
 	return imagePaths, nil
 }
+
+// ReadArchive extracts the images of the archive into the output dir, and returns them as a book described by the
+// ComicInfo of the archive. Archives without ComicInfo are a single chapter named after the archive.
+func ReadArchive(outputDir, archivePath string) (model.Book, error) {
+	pages, err := ExtractArchive(outputDir, archivePath)
+	if err != nil {
+		return model.Book{}, err
+	}
+	info, err := ReadArchiveComicInfo(archivePath)
+	if err != nil {
+		return model.Book{}, err
+	}
+	if info == nil {
+		info = &ComicInfo{}
+	}
+	name := strings.TrimSuffix(filepath.Base(archivePath), filepath.Ext(archivePath))
+	return info.Book(name, pages), nil
+}
+
+func extractFile(r io.Reader, dstPath string) error {
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, r); err != nil {
+		return err
+	}
+	return dst.Close()
+}
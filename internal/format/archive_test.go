@@ -0,0 +1,75 @@
+package format
+
+import (
+	"archive/zip"
+	"github.com/radam9/manga-tools/internal/model"
+	"github.com/radam9/manga-tools/internal/testutil"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSaveNamesPagesByType(t *testing.T) {
+	dir := t.TempDir()
+	// the extension is wrong on purpose, the pages are named after the type detected from their content.
+	newBook := func(t *testing.T, name string) model.Book {
+		page := filepath.Join(dir, name+".jpg")
+		testutil.WritePNG(t, page, 10, 20)
+		return model.Book{Chapters: []model.Chapter{{Pages: model.NewPagesFromPaths([]model.FilePath{page})}}}
+	}
+
+	t.Run("cbz", func(t *testing.T) {
+		outputPath := filepath.Join(dir, "book.cbz")
+		if err := (CBZ{}).Save(outputPath, newBook(t, "cbz")); err != nil {
+			t.Fatal(err)
+		}
+		r, err := zip.OpenReader(outputPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		var names []string
+		for _, f := range r.File {
+			if f.Name != "ComicInfo.xml" {
+				names = append(names, f.Name)
+			}
+		}
+		if expected := []string{"0000.png"}; !slices.Equal(names, expected) {
+			t.Errorf("expected: %v, got: %v", expected, names)
+		}
+	})
+
+	t.Run("images", func(t *testing.T) {
+		outputPath := filepath.Join(dir, "book")
+		if err := (Image{}).Save(outputPath, newBook(t, "images")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(outputPath, "0000.png")); err != nil {
+			t.Errorf("expected: 0000.png, got: %v", err)
+		}
+	})
+}
+
+func TestExtractRarArchive(t *testing.T) {
+	for _, name := range []string{"stored-v4.rar", "stored-v5.rar"} {
+		t.Run(name, func(t *testing.T) {
+			pages, err := ExtractArchive(t.TempDir(), filepath.Join("..", "archive", "testdata", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var contents []string
+			for _, page := range pages {
+				data, err := os.ReadFile(page)
+				if err != nil {
+					t.Fatal(err)
+				}
+				contents = append(contents, string(data))
+			}
+			// the pages are in natural order of their names in the archive.
+			if expected := []string{"0002.jpg", "dir/0001.jpg"}; !slices.Equal(contents, expected) {
+				t.Errorf("expected: %v, got: %v", expected, contents)
+			}
+		})
+	}
+}
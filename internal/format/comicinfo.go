@@ -0,0 +1,219 @@
+package format
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/radam9/manga-tools/internal/archive"
+	"github.com/radam9/manga-tools/internal/images"
+	"github.com/radam9/manga-tools/internal/model"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const comicInfoFileName = "ComicInfo.xml"
+
+// ComicInfo is the metadata file read by comic readers (Komga, Kavita, Mihon, ...) from the root of comic archives,
+// see https://anansi-project.github.io/docs/comicinfo/schemas/v2.0
+type ComicInfo struct {
+	XMLName         xml.Name        `xml:"ComicInfo"`
+	XMLNSXSI        string          `xml:"xmlns:xsi,attr"`
+	XMLNSXSD        string          `xml:"xmlns:xsd,attr"`
+	Title           string          `xml:"Title,omitempty"`
+	Series          string          `xml:"Series,omitempty"`
+	Number          string          `xml:"Number,omitempty"`
+	Volume          int             `xml:"Volume,omitempty"`
+	Summary         string          `xml:"Summary,omitempty"`
+	Year            int             `xml:"Year,omitempty"`
+	Writer          string          `xml:"Writer,omitempty"`
+	Penciller       string          `xml:"Penciller,omitempty"`
+	Tags            string          `xml:"Tags,omitempty"`
+	Web             string          `xml:"Web,omitempty"`
+	ScanInformation string          `xml:"ScanInformation,omitempty"`
+	PageCount       int             `xml:"PageCount"`
+	LanguageISO     string          `xml:"LanguageISO,omitempty"`
+	Manga           string          `xml:"Manga,omitempty"`
+	Pages           []ComicInfoPage `xml:"Pages>Page,omitempty"`
+}
+
+type ComicInfoPage struct {
+	Image       int    `xml:"Image,attr"`
+	Type        string `xml:"Type,attr,omitempty"`
+	ImageSize   int64  `xml:"ImageSize,attr,omitempty"`
+	ImageWidth  int    `xml:"ImageWidth,attr,omitempty"`
+	ImageHeight int    `xml:"ImageHeight,attr,omitempty"`
+	DoublePage  bool   `xml:"DoublePage,attr,omitempty"`
+	// Bookmark marks the first page of every chapter of bundles, so they can be split back into chapters.
+	Bookmark string `xml:"Bookmark,attr,omitempty"`
+}
+
+// NewComicInfo creates the ComicInfo of the book, the pages of the book must still be on disk.
+func NewComicInfo(book model.Book) ComicInfo {
+	manga := book.Manga
+	info := ComicInfo{
+		XMLNSXSI:  "http://www.w3.org/2001/XMLSchema-instance",
+		XMLNSXSD:  "http://www.w3.org/2001/XMLSchema",
+		Series:    manga.Title,
+		Volume:    book.Volume,
+		Summary:   manga.Description,
+		Year:      manga.Year,
+		Writer:    strings.Join(manga.Authors, ", "),
+		Penciller: strings.Join(manga.Artists, ", "),
+		Tags:      strings.Join(manga.Tags, ", "),
+		PageCount: book.PagesCount(),
+		Manga:     "Yes",
+	}
+	if manga.ID != "" {
+		info.Web = fmt.Sprintf("https://mangadex.org/title/%s", manga.ID)
+	}
+	if manga.Direction == model.DirectionRTL {
+		info.Manga = "YesAndRightToLeft"
+	}
+
+	if len(book.Chapters) == 1 {
+		chapter := book.Chapters[0]
+		info.Title = chapter.Title
+		if chapter.Number > 0 {
+			info.Number = strconv.FormatFloat(chapter.Number, 'f', -1, 64)
+		}
+	} else if book.Volume > 0 {
+		info.Title = fmt.Sprintf("Volume %d", book.Volume)
+	}
+	if len(book.Chapters) > 0 {
+		info.LanguageISO = book.Chapters[0].Language
+	}
+
+	var groups []string
+	for i, chapter := range book.Chapters {
+		for _, group := range chapter.Groups {
+			if !slices.Contains(groups, group) {
+				groups = append(groups, group)
+			}
+		}
+		for j, bookPage := range chapter.Pages {
+			page := ComicInfoPage{Image: len(info.Pages), Type: "Story", DoublePage: bookPage.DoublePage}
+			if page.Image == 0 {
+				page.Type = "FrontCover"
+			}
+			if j == 0 && len(book.Chapters) > 1 {
+				page.Bookmark = chapter.Label()
+				if page.Bookmark == "" {
+					page.Bookmark = fmt.Sprintf("Chapter %d", i+1)
+				}
+			}
+			page.ImageSize, page.ImageWidth, page.ImageHeight = images.Stats(bookPage.Path)
+			info.Pages = append(info.Pages, page)
+		}
+	}
+	info.ScanInformation = strings.Join(groups, ", ")
+	return info
+}
+
+func (c ComicInfo) Marshal() ([]byte, error) {
+	data, err := xml.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshaling %s: %w", comicInfoFileName, err)
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// ReadArchiveComicInfo returns the ComicInfo of the archive, or nil if it has none.
+func ReadArchiveComicInfo(archivePath string) (*ComicInfo, error) {
+	r, err := archive.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	for {
+		name, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading archive %q: %w", archivePath, err)
+		}
+		if !strings.EqualFold(path.Base(name), comicInfoFileName) {
+			continue
+		}
+
+		var info ComicInfo
+		if err := xml.NewDecoder(r).Decode(&info); err != nil {
+			return nil, fmt.Errorf("parsing %s of %q: %w", comicInfoFileName, archivePath, err)
+		}
+		return &info, nil
+	}
+}
+
+// Direction returns the reading direction of the ComicInfo, or an empty direction if it doesn't say it's right to left.
+func (c ComicInfo) Direction() model.Direction {
+	if c.Manga == "YesAndRightToLeft" {
+		return model.DirectionRTL
+	}
+	return ""
+}
+
+// Book returns the book described by the ComicInfo, made of the pages in archive order. The pages are split into
+// chapters at the bookmarks, otherwise the name is the title of the chapter when the ComicInfo has neither a title
+// nor a number.
+func (c ComicInfo) Book(name string, pages []model.FilePath) model.Book {
+	manga := model.Manga{
+		Title:       c.Series,
+		Description: c.Summary,
+		Authors:     splitList(c.Writer),
+		Artists:     splitList(c.Penciller),
+		Tags:        splitList(c.Tags),
+		Year:        c.Year,
+		Direction:   c.Direction(),
+	}
+
+	groups := splitList(c.ScanInformation)
+	chapter := model.Chapter{Title: c.Title, Volume: c.Volume, Language: c.LanguageISO, Groups: groups, Pages: model.NewPagesFromPaths(pages)}
+	if number, err := strconv.ParseFloat(c.Number, 64); err == nil {
+		chapter.Number = number
+	}
+	if chapter.Title == "" && chapter.Number == 0 {
+		chapter.Title = name
+	}
+	bookmarks := map[int]string{}
+	for _, page := range c.Pages {
+		if page.Image >= 0 && page.Image < len(chapter.Pages) {
+			chapter.Pages[page.Image].DoublePage = page.DoublePage
+			if page.Bookmark != "" {
+				bookmarks[page.Image] = page.Bookmark
+			}
+		}
+	}
+	book := model.Book{Manga: manga, Volume: c.Volume}
+	if len(bookmarks) == 0 {
+		book.Chapters = []model.Chapter{chapter}
+		return book
+	}
+
+	for i, page := range chapter.Pages {
+		title, found := bookmarks[i]
+		if i == 0 && !found {
+			// pages before the first bookmark.
+			title, found = name, true
+		}
+		if found {
+			book.Chapters = append(book.Chapters, model.Chapter{Title: title, Volume: c.Volume, Language: c.LanguageISO, Groups: groups})
+		}
+		last := &book.Chapters[len(book.Chapters)-1]
+		last.Pages = append(last.Pages, page)
+	}
+	return book
+}
+
+func splitList(s string) []string {
+	var result []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,147 @@
+package format
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"github.com/radam9/manga-tools/internal/model"
+	"github.com/radam9/manga-tools/internal/testutil"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveCBZComicInfo(t *testing.T) {
+	dir := t.TempDir()
+	var pages []model.FilePath
+	for i, size := range [][2]int{{10, 20}, {30, 20}} {
+		path := filepath.Join(dir, fmt.Sprintf("page%d.png", i))
+		testutil.WritePNG(t, path, size[0], size[1])
+		pages = append(pages, path)
+	}
+
+	book := model.Book{
+		Manga: model.Manga{
+			ID:        "319df2e2-e6a6-4e3a-a31c-68539c140a84",
+			Title:     "Slam Dunk",
+			Authors:   []string{"Inoue Takehiko"},
+			Direction: model.DirectionRTL,
+		},
+		Volume: 2,
+		Chapters: []model.Chapter{
+			{Title: "The Genius", Number: 10.5, Volume: 2, Language: "en", Groups: []string{"Shohoku", "Ryonan"}, Pages: model.NewPagesFromPaths(pages)},
+		},
+	}
+
+	outputPath := filepath.Join(dir, "out", "book.cbz")
+	if err := (CBZ{}).Save(outputPath, book); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.OpenReader(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if len(r.File) != 3 || r.File[0].Name != comicInfoFileName {
+		t.Fatalf("expected ComicInfo.xml and 2 pages, got %d files", len(r.File))
+	}
+	f, err := r.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got ComicInfo
+	if err := xml.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][2]string{
+		"Series":          {"Slam Dunk", got.Series},
+		"Title":           {"The Genius", got.Title},
+		"Number":          {"10.5", got.Number},
+		"Writer":          {"Inoue Takehiko", got.Writer},
+		"Manga":           {"YesAndRightToLeft", got.Manga},
+		"LanguageISO":     {"en", got.LanguageISO},
+		"ScanInformation": {"Shohoku, Ryonan", got.ScanInformation},
+		"Web":             {"https://mangadex.org/title/319df2e2-e6a6-4e3a-a31c-68539c140a84", got.Web},
+	}
+	for field, values := range expected {
+		if values[0] != values[1] {
+			t.Errorf("%s expected: %s, got: %s", field, values[0], values[1])
+		}
+	}
+	if got.Volume != 2 || got.PageCount != 2 || len(got.Pages) != 2 {
+		t.Errorf("expected volume 2 with 2 pages, got: volume %d, page count %d, pages %d", got.Volume, got.PageCount, len(got.Pages))
+	}
+	if page := got.Pages[1]; page.Image != 1 || page.Type != "Story" || page.ImageWidth != 30 || page.ImageHeight != 20 || page.ImageSize == 0 {
+		t.Errorf("unexpected page info: %+v", page)
+	}
+}
+
+func TestReadArchiveMerge(t *testing.T) {
+	dir := t.TempDir()
+	newArchive := func(name string, number float64, authors []string, pagesCount int) string {
+		var pages []model.FilePath
+		for i := range pagesCount {
+			path := filepath.Join(dir, fmt.Sprintf("%s-page%d.png", name, i))
+			testutil.WritePNG(t, path, 10+i, 20)
+			pages = append(pages, path)
+		}
+		book := model.Book{
+			Manga:    model.Manga{Title: "Slam Dunk", Authors: authors, Direction: model.DirectionRTL},
+			Volume:   1,
+			Chapters: []model.Chapter{{Number: number, Volume: 1, Pages: model.NewPagesFromPaths(pages)}},
+		}
+		book.Chapters[0].Pages[pagesCount-1].DoublePage = true
+		path := filepath.Join(dir, name+".cbz")
+		if err := (CBZ{}).Save(path, book); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	var books []model.Book
+	for i, path := range []string{
+		newArchive("chapter-1", 1, []string{"Inoue Takehiko"}, 2),
+		newArchive("chapter-2", 2, []string{"Inoue Takehiko", "Yasuo Toyama"}, 3),
+	} {
+		book, err := ReadArchive(filepath.Join(dir, "extracted", fmt.Sprint(i)), path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		books = append(books, book)
+	}
+	merged := model.MergeBooks(books)
+
+	if merged.Manga.Title != "Slam Dunk" || merged.Manga.Direction != model.DirectionRTL || merged.Volume != 1 {
+		t.Errorf("unexpected merged manga: %+v, volume %d", merged.Manga, merged.Volume)
+	}
+	if len(merged.Manga.Authors) != 2 {
+		t.Errorf("expected: 2 authors, got: %v", merged.Manga.Authors)
+	}
+	if len(merged.Chapters) != 2 || merged.Chapters[1].Number != 2 || merged.PagesCount() != 5 {
+		t.Fatalf("unexpected merged chapters: %+v", merged.Chapters)
+	}
+	if pages := merged.Chapters[1].Pages; !pages[2].DoublePage || pages[0].DoublePage {
+		t.Errorf("expected: last page double, got: %+v", pages)
+	}
+
+	// the chapters of the bundle are bookmarked, and read back as chapters.
+	bundlePath := filepath.Join(dir, "bundle.cbz")
+	if err := (CBZ{}).Save(bundlePath, merged); err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := ReadArchive(filepath.Join(dir, "extracted", "bundle"), bundlePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bundle.Chapters) != 2 || bundle.Chapters[1].Title != "Chapter 2" || len(bundle.Chapters[1].Pages) != 3 {
+		t.Errorf("expected: 2 bookmarked chapters, got: %+v", bundle.Chapters)
+	}
+}
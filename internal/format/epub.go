@@ -0,0 +1,271 @@
+package format
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/radam9/manga-tools/internal/images"
+	"github.com/radam9/manga-tools/internal/model"
+	"io"
+	"os"
+	"text/template"
+	"time"
+)
+
+// EPUB saves the pages as a fixed-layout EPUB3, with one XHTML document per page.
+type EPUB struct {
+	Layout Layout
+}
+
+func (e EPUB) Save(filePath string, book model.Book) error {
+	if book.PagesCount() == 0 {
+		return errors.New("no files to pack")
+	}
+	if err := createParentDir(filePath); err != nil {
+		return err
+	}
+
+	// the EPUB core media types don't include tiff and avif images.
+	tempDir, err := os.MkdirTemp("", "manga-tools-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	pkg, err := newEPUBPackage(book, tempDir)
+	if err != nil {
+		return err
+	}
+
+	buff, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer buff.Close()
+	w := zip.NewWriter(buff)
+
+	// the mimetype must be the first file in the archive, and must not be compressed.
+	f, err := w.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	documents := []epubDocument{
+		{name: "META-INF/container.xml", template: epubContainerTemplate, data: nil},
+		{name: "OEBPS/content.opf", template: epubPackageTemplate, data: pkg},
+		{name: "OEBPS/nav.xhtml", template: epubNavTemplate, data: pkg},
+	}
+	for _, page := range pkg.Pages {
+		documents = append(documents, epubDocument{name: "OEBPS/" + page.Document, template: epubPageTemplate, data: page})
+	}
+	for _, document := range documents {
+		f, err := w.Create(document.name)
+		if err != nil {
+			return err
+		}
+		if err := document.template.Execute(f, document.data); err != nil {
+			return fmt.Errorf("writing %s: %w", document.name, err)
+		}
+	}
+
+	for _, page := range pkg.Pages {
+		if err := copyFileToArchive(w, "OEBPS/"+page.Image, page.path); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+func (e EPUB) OutputPath(outputDir string, mangaTitle string, volume int, chapterTitle string, chapter float64) string {
+	filePath := getOutputFilePath(outputDir, e.Layout, mangaTitle, volume, chapter, chapterTitle)
+	return filePath + ".epub"
+}
+
+func copyFileToArchive(w *zip.Writer, name string, path model.FilePath) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// images are already compressed, storing them avoids compressing them a second time for nothing.
+	dst, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+type epubDocument struct {
+	name     string
+	template *template.Template
+	data     any
+}
+
+type epubPackage struct {
+	ID          string
+	Title       string
+	Language    string
+	Creators    []string
+	Description string
+	Modified    string
+	Direction   model.Direction
+	Pages       []epubPage
+	Chapters    []epubChapter
+}
+
+type epubPage struct {
+	Number    int
+	Document  string
+	Image     string
+	MediaType string
+	Width     int
+	Height    int
+	Title     string
+	path      model.FilePath
+}
+
+type epubChapter struct {
+	Title    string
+	Document string
+}
+
+func newEPUBPackage(book model.Book, tempDir string) (epubPackage, error) {
+	pkg := epubPackage{
+		ID:          uuid.NewSHA1(uuid.NameSpaceURL, []byte(book.Manga.ID+book.Title())).String(),
+		Title:       book.Title(),
+		Language:    "und",
+		Creators:    append(append([]string{}, book.Manga.Authors...), book.Manga.Artists...),
+		Description: book.Manga.Description,
+		Modified:    time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		Direction:   book.Manga.Direction,
+	}
+	if pkg.Direction == "" {
+		pkg.Direction = model.DirectionLTR
+	}
+	if len(book.Chapters) > 0 && book.Chapters[0].Language != "" {
+		pkg.Language = book.Chapters[0].Language
+	}
+
+	for i, chapter := range book.Chapters {
+		pagePaths, err := images.EnsureTypes(model.GetSliceOfPagePathsFromPages(chapter.Pages), tempDir, images.JPEG, images.PNG, images.GIF, images.WebP)
+		if err != nil {
+			return epubPackage{}, err
+		}
+		for j, pagePath := range pagePaths {
+			imageType, err := images.Detect(pagePath)
+			if err != nil {
+				return epubPackage{}, err
+			}
+			_, width, height := images.Stats(pagePath)
+			if width == 0 || height == 0 {
+				width, height = 1200, 1800
+			}
+
+			number := len(pkg.Pages) + 1
+			page := epubPage{
+				Number:    number,
+				Document:  fmt.Sprintf("page-%04d.xhtml", number),
+				Image:     fmt.Sprintf("images/%04d%s", number, imageType.Extension()),
+				MediaType: imageType.MediaType(),
+				Width:     width,
+				Height:    height,
+				Title:     fmt.Sprintf("%s - %d", pkg.Title, number),
+				path:      pagePath,
+			}
+			pkg.Pages = append(pkg.Pages, page)
+
+			if j == 0 {
+				label := chapter.Label()
+				if label == "" {
+					label = fmt.Sprintf("Chapter %d", i+1)
+				}
+				pkg.Chapters = append(pkg.Chapters, epubChapter{Title: label, Document: page.Document})
+			}
+		}
+	}
+	return pkg, nil
+}
+
+var epubFuncs = template.FuncMap{
+	"escape": template.HTMLEscapeString,
+}
+
+var epubContainerTemplate = template.Must(template.New("container").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`))
+
+var epubPackageTemplate = template.Must(template.New("package").Funcs(epubFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="{{ escape .Language }}">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="book-id">urn:uuid:{{ .ID }}</dc:identifier>
+    <dc:title>{{ escape .Title }}</dc:title>
+    <dc:language>{{ escape .Language }}</dc:language>
+    {{- range .Creators }}
+    <dc:creator>{{ escape . }}</dc:creator>
+    {{- end }}
+    {{- if .Description }}
+    <dc:description>{{ escape .Description }}</dc:description>
+    {{- end }}
+    <meta property="dcterms:modified">{{ .Modified }}</meta>
+    <meta property="rendition:layout">pre-paginated</meta>
+    <meta property="rendition:orientation">auto</meta>
+    <meta property="rendition:spread">landscape</meta>
+    <meta name="cover" content="image-0001"/>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    {{- range .Pages }}
+    <item id="page-{{ printf "%04d" .Number }}" href="{{ .Document }}" media-type="application/xhtml+xml"/>
+    <item id="image-{{ printf "%04d" .Number }}" href="{{ .Image }}" media-type="{{ .MediaType }}"{{ if eq .Number 1 }} properties="cover-image"{{ end }}/>
+    {{- end }}
+  </manifest>
+  <spine page-progression-direction="{{ .Direction }}">
+    {{- range .Pages }}
+    <itemref idref="page-{{ printf "%04d" .Number }}"/>
+    {{- end }}
+  </spine>
+</package>
+`))
+
+var epubNavTemplate = template.Must(template.New("nav").Funcs(epubFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{ escape .Language }}">
+<head>
+  <title>{{ escape .Title }}</title>
+</head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <h1>{{ escape .Title }}</h1>
+    <ol>
+      {{- range .Chapters }}
+      <li><a href="{{ .Document }}">{{ escape .Title }}</a></li>
+      {{- end }}
+    </ol>
+  </nav>
+</body>
+</html>
+`))
+
+var epubPageTemplate = template.Must(template.New("page").Funcs(epubFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head>
+  <title>{{ escape .Title }}</title>
+  <meta name="viewport" content="width={{ .Width }}, height={{ .Height }}"/>
+  <style>body { margin: 0; padding: 0; } img { display: block; width: 100%; height: 100%; object-fit: contain; }</style>
+</head>
+<body>
+  <img src="{{ .Image }}" alt="{{ .Number }}"/>
+</body>
+</html>
+`))
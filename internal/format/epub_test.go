@@ -0,0 +1,164 @@
+package format
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"github.com/radam9/manga-tools/internal/model"
+	"github.com/radam9/manga-tools/internal/testutil"
+	"io"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func readZipEntry(t *testing.T, r *zip.ReadCloser, name string) []byte {
+	t.Helper()
+	for _, f := range r.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	t.Fatalf("expected: %s in the archive, got: nothing", name)
+	return nil
+}
+
+func TestSaveEPUB(t *testing.T) {
+	dir := t.TempDir()
+	var chapters []model.Chapter
+	for i := range 2 {
+		var pages []model.FilePath
+		for j := range 2 {
+			path := filepath.Join(dir, fmt.Sprintf("%d-%d.png", i, j))
+			testutil.WritePNG(t, path, 10, 20)
+			pages = append(pages, path)
+		}
+		chapters = append(chapters, model.Chapter{Number: float64(i + 1), Language: "en", Pages: model.NewPagesFromPaths(pages)})
+	}
+	book := model.Book{
+		Manga:    model.Manga{Title: "Tom & Jerry <Special>", Direction: model.DirectionRTL},
+		Volume:   1,
+		Chapters: chapters,
+	}
+
+	outputPath := filepath.Join(dir, "out", "book.epub")
+	if err := (EPUB{}).Save(outputPath, book); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.OpenReader(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	t.Run("mimetype", func(t *testing.T) {
+		first := r.File[0]
+		if first.Name != "mimetype" || first.Method != zip.Store {
+			t.Errorf("expected: a stored mimetype first, got: %s with method %d", first.Name, first.Method)
+		}
+		if data := readZipEntry(t, r, "mimetype"); string(data) != "application/epub+zip" {
+			t.Errorf("expected: application/epub+zip, got: %s", data)
+		}
+	})
+
+	t.Run("package", func(t *testing.T) {
+		var pkg struct {
+			Title string `xml:"metadata>title"`
+			Items []struct {
+				ID         string `xml:"id,attr"`
+				Properties string `xml:"properties,attr"`
+			} `xml:"manifest>item"`
+			Spine struct {
+				Direction string     `xml:"page-progression-direction,attr"`
+				Items     []struct{} `xml:"itemref"`
+			} `xml:"spine"`
+		}
+		if err := xml.Unmarshal(readZipEntry(t, r, "OEBPS/content.opf"), &pkg); err != nil {
+			t.Fatal(err)
+		}
+		if expected := "Tom & Jerry <Special> - Volume 1"; pkg.Title != expected {
+			t.Errorf("expected: %s, got: %s", expected, pkg.Title)
+		}
+		if pkg.Spine.Direction != "rtl" {
+			t.Errorf("expected: rtl, got: %s", pkg.Spine.Direction)
+		}
+		if len(pkg.Spine.Items) != 4 {
+			t.Errorf("expected: 4 pages in the spine, got: %d", len(pkg.Spine.Items))
+		}
+		var cover string
+		for _, item := range pkg.Items {
+			if item.Properties == "cover-image" {
+				cover = item.ID
+			}
+		}
+		if cover != "image-0001" {
+			t.Errorf("expected: image-0001, got: %s", cover)
+		}
+	})
+
+	t.Run("nav", func(t *testing.T) {
+		var nav struct {
+			Title string `xml:"head>title"`
+			Links []struct {
+				Href  string `xml:"href,attr"`
+				Title string `xml:",chardata"`
+			} `xml:"body>nav>ol>li>a"`
+		}
+		if err := xml.Unmarshal(readZipEntry(t, r, "OEBPS/nav.xhtml"), &nav); err != nil {
+			t.Fatal(err)
+		}
+		if expected := "Tom & Jerry <Special> - Volume 1"; nav.Title != expected {
+			t.Errorf("expected: %s, got: %s", expected, nav.Title)
+		}
+		expected := []string{"Chapter 1 page-0001.xhtml", "Chapter 2 page-0003.xhtml"}
+		var links []string
+		for _, link := range nav.Links {
+			links = append(links, link.Title+" "+link.Href)
+		}
+		if !slices.Equal(links, expected) {
+			t.Errorf("expected: %v, got: %v", expected, links)
+		}
+	})
+}
+
+func TestSaveEPUBEscapesLanguage(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "page.png")
+	testutil.WritePNG(t, path, 10, 20)
+	language := `en" dir="rtl`
+	book := model.Book{
+		Manga:    model.Manga{Title: "Slam Dunk"},
+		Chapters: []model.Chapter{{Number: 1, Language: language, Pages: model.NewPagesFromPaths([]model.FilePath{path})}},
+	}
+
+	outputPath := filepath.Join(dir, "book.epub")
+	if err := (EPUB{}).Save(outputPath, book); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.OpenReader(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var pkg struct {
+		Lang     string `xml:"lang,attr"`
+		Language string `xml:"metadata>language"`
+	}
+	if err := xml.Unmarshal(readZipEntry(t, r, "OEBPS/content.opf"), &pkg); err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Lang != language || pkg.Language != language {
+		t.Errorf("expected: %s, got: %s and %s", language, pkg.Lang, pkg.Language)
+	}
+}
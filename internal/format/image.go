@@ -1,33 +1,31 @@
 package format
 
 import (
-	"errors"
 	"fmt"
+	"github.com/radam9/manga-tools/internal/images"
 	"github.com/radam9/manga-tools/internal/model"
 	"io"
 	"os"
 	"path/filepath"
-	"syscall"
 )
 
-type Image struct{}
-
-func (i Image) Save(outputPath string, pages []model.FilePath) error {
-	for index, page := range pages {
-		if err := os.MkdirAll(outputPath, 0755); err != nil {
-			return fmt.Errorf("save pages as images: mkdirall: %w", err)
-		}
+type Image struct {
+	Layout Layout
+}
 
-		outputFilename := filepath.Join(outputPath, fmt.Sprintf("%04d.png", index))
-		err := os.Rename(page, outputFilename)
-		if err == nil {
-			continue
-		}
-		if !errors.Is(err, syscall.EXDEV) {
-			return fmt.Errorf("save pages as images: move: %w", err)
+// Save copies the pages into the output directory, the pages are left in place as they can be the source files.
+func (i Image) Save(outputPath string, book model.Book) error {
+	if err := os.MkdirAll(outputPath, 0755); err != nil {
+		return fmt.Errorf("save pages as images: mkdirall: %w", err)
+	}
+	for index, page := range book.PagePaths() {
+		imageType, err := images.Detect(page)
+		if err != nil {
+			return fmt.Errorf("save pages as images: %w", err)
 		}
 
-		if err := i.crossDeviceCopy(outputFilename, pages[index]); err != nil {
+		outputFilename := filepath.Join(outputPath, fmt.Sprintf("%04d%s", index, imageType.Extension()))
+		if err := copyFile(outputFilename, page); err != nil {
 			return fmt.Errorf("save pages as images: copy: %w", err)
 		}
 	}
@@ -35,10 +33,10 @@ func (i Image) Save(outputPath string, pages []model.FilePath) error {
 }
 
 func (i Image) OutputPath(outputDir string, mangaTitle string, volume int, chapterTitle string, chapter float64) string {
-	return getOutputFilePath(outputDir, mangaTitle, volume, chapter, chapterTitle)
+	return getOutputFilePath(outputDir, i.Layout, mangaTitle, volume, chapter, chapterTitle)
 }
 
-func (i Image) crossDeviceCopy(outputPath string, page model.FilePath) error {
+func copyFile(outputPath string, page model.FilePath) error {
 	src, err := os.Open(page)
 	if err != nil {
 		return err
@@ -54,6 +52,5 @@ func (i Image) crossDeviceCopy(outputPath string, page model.FilePath) error {
 	if _, err := io.Copy(dst, src); err != nil {
 		return err
 	}
-
-	return os.Remove(page)
+	return dst.Close()
 }
@@ -0,0 +1,58 @@
+package format
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Layout is the directory structure the output files are written into.
+type Layout string
+
+const (
+	// LayoutFlat writes every output directly into the output directory.
+	LayoutFlat Layout = "flat"
+	// LayoutSeries writes the outputs into `<OutputDir>/<Series>/`.
+	LayoutSeries Layout = "series"
+	// LayoutVolume writes the outputs into `<OutputDir>/<Series>/Volume NN/`,
+	// outputs without a volume are written into the series directory.
+	LayoutVolume Layout = "volume"
+)
+
+var Layouts = []Layout{LayoutFlat, LayoutSeries, LayoutVolume}
+
+func ParseLayout(s string) (Layout, error) {
+	for _, layout := range Layouts {
+		if strings.EqualFold(s, string(layout)) {
+			return layout, nil
+		}
+	}
+	return "", fmt.Errorf("unknown layout %q, must be one of %v", s, Layouts)
+}
+
+// Dir returns the directory an output of the given manga and volume is written to.
+func (l Layout) Dir(outputDir string, mangaTitle string, volume int) string {
+	switch l {
+	case LayoutSeries:
+		return filepath.Join(outputDir, sanitizeFileName(mangaTitle))
+	case LayoutVolume:
+		if volume <= 0 {
+			return filepath.Join(outputDir, sanitizeFileName(mangaTitle))
+		}
+		return filepath.Join(outputDir, sanitizeFileName(mangaTitle), fmt.Sprintf("Volume %02d", volume))
+	default:
+		return outputDir
+	}
+}
+
+// sanitizeFileName replaces the characters that can't be part of a file name.
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		return r
+	}, name)
+	return strings.TrimRight(strings.TrimSpace(name), ".")
+}
@@ -0,0 +1,51 @@
+package format
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestOutputPathLayout(t *testing.T) {
+	tests := []struct {
+		name     string
+		layout   Layout
+		volume   int
+		chapter  float64
+		expected string
+	}{
+		{name: "flat chapter", layout: LayoutFlat, volume: 2, chapter: 10, expected: "out/Slam Dunk - volume 2 - chapter 0010.0.cbz"},
+		{name: "empty layout is flat", layout: "", volume: 2, chapter: 10, expected: "out/Slam Dunk - volume 2 - chapter 0010.0.cbz"},
+		{name: "series chapter", layout: LayoutSeries, volume: 2, chapter: 10, expected: "out/Slam Dunk/Slam Dunk - volume 2 - chapter 0010.0.cbz"},
+		{name: "volume chapter", layout: LayoutVolume, volume: 2, chapter: 10, expected: "out/Slam Dunk/Volume 02/Slam Dunk - volume 2 - chapter 0010.0.cbz"},
+		{name: "volume chapter without volume", layout: LayoutVolume, volume: 0, chapter: 10, expected: "out/Slam Dunk/Slam Dunk - chapter 0010.0.cbz"},
+		{name: "volume bundle", layout: LayoutVolume, volume: 3, chapter: 0, expected: "out/Slam Dunk/Volume 03/Slam Dunk - volume 3.cbz"},
+		{name: "full bundle", layout: LayoutVolume, volume: 0, chapter: 0, expected: "out/Slam Dunk/Slam Dunk.cbz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CBZ{Layout: tt.layout}.OutputPath("out", "Slam Dunk", tt.volume, "", tt.chapter)
+			if got != filepath.FromSlash(tt.expected) {
+				t.Errorf("expected: %s, got: %s", tt.expected, got)
+			}
+		})
+	}
+
+	t.Run("title is sanitized", func(t *testing.T) {
+		got := Image{Layout: LayoutSeries}.OutputPath("out", "Fate/Zero", 0, "", 1)
+		expected := filepath.FromSlash("out/Fate_Zero/Fate_Zero - chapter 0001.0")
+		if got != expected {
+			t.Errorf("expected: %s, got: %s", expected, got)
+		}
+	})
+
+	t.Run("parse layout", func(t *testing.T) {
+		if _, err := ParseLayout("unknown"); err == nil {
+			t.Errorf("expected an error for an unknown layout")
+		}
+		got, err := ParseLayout("Volume")
+		if err != nil || got != LayoutVolume {
+			t.Errorf("expected: %s, got: %s (%v)", LayoutVolume, got, err)
+		}
+	})
+}
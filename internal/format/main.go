@@ -3,11 +3,12 @@ package format
 import (
 	"fmt"
 	"github.com/radam9/manga-tools/internal/model"
+	"os"
 	"path/filepath"
 	"strings"
 )
 
-func getOutputFilePath(outputDir string, mangaTitle string, volume int, chapter float64, chapterTitle string) string {
+func getOutputFilePath(outputDir string, layout Layout, mangaTitle string, volume int, chapter float64, chapterTitle string) string {
 	var filename strings.Builder
 	filename.WriteString(mangaTitle)
 
@@ -20,21 +21,56 @@ func getOutputFilePath(outputDir string, mangaTitle string, volume int, chapter
 	if chapterTitle != "" {
 		filename.WriteString(fmt.Sprintf(" - %s", chapterTitle))
 	}
-	return filepath.Join(outputDir, filename.String())
+	return filepath.Join(layout.Dir(outputDir, mangaTitle, volume), sanitizeFileName(filename.String()))
+}
+
+// createParentDir creates the directory the output file is written to, as the layout can nest it in the output dir.
+func createParentDir(filePath string) error {
+	dir := filepath.Dir(filePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating output directory %q: %w", dir, err)
+	}
+	return nil
 }
 
 type Format interface {
-	Save(outputPath string, pages []model.FilePath) error
+	Save(outputPath string, book model.Book) error
 	OutputPath(outputDir string, mangaTitle string, volume int, chapterTitle string, chapter float64) string
 }
 
-func SelectFormat(cbr, cbz, pdf bool) Format {
-	if pdf {
-		return PDF{}
-	} else if cbr {
-		return CBR{}
-	} else if cbz {
-		return CBZ{}
+// Formats are the names of the output formats.
+var Formats = []string{"pdf", "cbz", "cbr", "cb7", "cbt", "epub", "images"}
+
+// formats builds the output formats by name, it's the registry behind NewFormat.
+var formats = map[string]func(layout Layout, pdfOptions PDFOptions) Format{
+	"pdf":    func(layout Layout, pdfOptions PDFOptions) Format { return PDF{Layout: layout, Options: pdfOptions} },
+	"cbz":    func(layout Layout, pdfOptions PDFOptions) Format { return CBZ{Layout: layout} },
+	"cbr":    func(layout Layout, pdfOptions PDFOptions) Format { return CBR{Layout: layout} },
+	"cb7":    func(layout Layout, pdfOptions PDFOptions) Format { return CB7{Layout: layout} },
+	"cbt":    func(layout Layout, pdfOptions PDFOptions) Format { return CBT{Layout: layout} },
+	"epub":   func(layout Layout, pdfOptions PDFOptions) Format { return EPUB{Layout: layout} },
+	"images": func(layout Layout, pdfOptions PDFOptions) Format { return Image{Layout: layout} },
+}
+
+// NewFormat returns the output format with the given name, one of Formats.
+func NewFormat(name string, layout Layout, pdfOptions PDFOptions) (Format, error) {
+	newFormat, found := formats[strings.ToLower(name)]
+	if !found {
+		return nil, fmt.Errorf("unknown format %q, must be one of %v", name, Formats)
+	}
+	return newFormat(layout, pdfOptions), nil
+}
+
+func SelectFormat(cbr, cbz, cb7, cbt, pdf, epub bool, layout Layout, pdfOptions PDFOptions) Format {
+	name := "images"
+	for _, selected := range []struct {
+		enabled bool
+		name    string
+	}{{pdf, "pdf"}, {epub, "epub"}, {cbr, "cbr"}, {cbz, "cbz"}, {cb7, "cb7"}, {cbt, "cbt"}} {
+		if selected.enabled {
+			name = selected.name
+			break
+		}
 	}
-	return Image{}
+	return formats[name](layout, pdfOptions)
 }
@@ -0,0 +1,29 @@
+package format
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestNewFormat(t *testing.T) {
+	for _, name := range Formats {
+		t.Run(name, func(t *testing.T) {
+			f, err := NewFormat(name, LayoutFlat, PDFOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			outputPath := f.OutputPath("out", "Slam Dunk", 0, "", 0)
+			expected := filepath.Join("out", "Slam Dunk")
+			if name != "images" {
+				expected += "." + name
+			}
+			if outputPath != expected {
+				t.Errorf("expected: %s, got: %s", expected, outputPath)
+			}
+		})
+	}
+
+	if _, err := NewFormat("docx", LayoutFlat, PDFOptions{}); err == nil {
+		t.Errorf("expected: error for an unknown format, got: nil")
+	}
+}
@@ -0,0 +1,299 @@
+package format
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/maruel/natural"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/radam9/manga-tools/internal"
+	"github.com/radam9/manga-tools/internal/archive"
+	"github.com/radam9/manga-tools/internal/images"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Pages reads the pages of a comic archive, a pdf or an image folder one at a time, without extracting the file.
+type Pages interface {
+	Count() int
+	// Read returns the name and the content of the image of the page, the pages are numbered from 0.
+	Read(i int) (string, []byte, error)
+	Close() error
+}
+
+// OpenPages lists the pages of the file, the images of archives (epubs included) are the pages in natural order.
+func OpenPages(filePath string) (Pages, error) {
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if stat.IsDir() {
+		return openDirPages(filePath)
+	}
+	if strings.EqualFold(filepath.Ext(filePath), ".pdf") {
+		return openPDFPages(filePath)
+	}
+	return openArchivePages(filePath)
+}
+
+func checkPage(i, count int) error {
+	if i < 0 || i >= count {
+		return fmt.Errorf("page %d is out of the %d pages", i+1, count)
+	}
+	return nil
+}
+
+type dirPages struct {
+	paths []string
+}
+
+func openDirPages(dir string) (*dirPages, error) {
+	children, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("listing directory %q contents: %w", dir, err)
+	}
+	var pages dirPages
+	for _, child := range internal.SortDirEntry(children) {
+		if !child.IsDir() && internal.IsImageFile(child.Name()) {
+			pages.paths = append(pages.paths, filepath.Join(dir, child.Name()))
+		}
+	}
+	return &pages, nil
+}
+
+func (p *dirPages) Count() int {
+	return len(p.paths)
+}
+
+func (p *dirPages) Read(i int) (string, []byte, error) {
+	if err := checkPage(i, len(p.paths)); err != nil {
+		return "", nil, err
+	}
+	data, err := os.ReadFile(p.paths[i])
+	return filepath.Base(p.paths[i]), data, err
+}
+
+func (p *dirPages) Close() error {
+	return nil
+}
+
+// archivePages reads the pages of zip archives directly. The other archives can only be read in order, they're kept
+// open at the last page read, so reading the pages in order walks the archive once rather than once per page.
+type archivePages struct {
+	path  string
+	isZip bool
+	names []string
+	// positions are the positions of the pages among the files of the archive.
+	positions map[string]int
+
+	mu sync.Mutex
+	r  archive.Reader
+	// next is the position of the file r returns next.
+	next int
+}
+
+func openArchivePages(archivePath string) (*archivePages, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	archiveType, err := archive.Detect(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("detecting archive type of %q: %w", archivePath, err)
+	}
+
+	pages := archivePages{path: archivePath, isZip: archiveType == archive.TypeZIP, positions: map[string]int{}}
+	position := 0
+	err = walkArchive(archivePath, func(name string, r io.Reader) error {
+		if _, ok := pages.positions[name]; !ok && internal.IsImageFile(name) {
+			pages.names = append(pages.names, name)
+			pages.positions[name] = position
+		}
+		position++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing pages of %q: %w", archivePath, err)
+	}
+	slices.SortStableFunc(pages.names, func(a, b string) int {
+		if natural.Less(a, b) {
+			return -1
+		} else if natural.Less(b, a) {
+			return 1
+		}
+		return 0
+	})
+	return &pages, nil
+}
+
+func (p *archivePages) Count() int {
+	return len(p.names)
+}
+
+func (p *archivePages) Read(i int) (string, []byte, error) {
+	if err := checkPage(i, len(p.names)); err != nil {
+		return "", nil, err
+	}
+	name := p.names[i]
+	if p.isZip {
+		data, err := readZipFile(p.path, name)
+		return path.Base(name), data, err
+	}
+
+	data, err := p.readInOrder(name)
+	if err != nil {
+		return "", nil, err
+	}
+	return path.Base(name), data, nil
+}
+
+// readInOrder reads the page from the open archive, the archive is read again from the start for the pages behind it.
+func (p *archivePages) readInOrder(name string) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	position := p.positions[name]
+	if p.r == nil || position < p.next {
+		p.closeReader()
+		r, err := archive.Open(p.path)
+		if err != nil {
+			return nil, err
+		}
+		p.r, p.next = r, 0
+	}
+
+	for p.next <= position {
+		entry, err := p.r.Next()
+		if err != nil {
+			p.closeReader()
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("page %q is missing from %q", name, p.path)
+			}
+			return nil, fmt.Errorf("reading archive %q: %w", p.path, err)
+		}
+		p.next++
+		if p.next-1 == position && entry == name {
+			data, err := io.ReadAll(p.r)
+			if err != nil {
+				p.closeReader()
+				return nil, fmt.Errorf("reading page %q of %q: %w", name, p.path, err)
+			}
+			return data, nil
+		}
+	}
+	p.closeReader()
+	return nil, fmt.Errorf("page %q is missing from %q", name, p.path)
+}
+
+func (p *archivePages) closeReader() {
+	if p.r != nil {
+		p.r.Close()
+		p.r = nil
+	}
+}
+
+func (p *archivePages) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closeReader()
+	return nil
+}
+
+func readZipFile(zipPath, name string) ([]byte, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	// the entries are matched by name rather than opened as paths, as their names aren't always valid paths.
+	for _, file := range r.File {
+		if file.Name != name {
+			continue
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(f)
+	}
+	return nil, fmt.Errorf("page %q is missing from %q", name, zipPath)
+}
+
+// pdfPages keeps the pdf read, and extracts the images of a page when it's read.
+type pdfPages struct {
+	mu  sync.Mutex
+	ctx *model.Context
+}
+
+func openPDFPages(pdfPath string) (*pdfPages, error) {
+	f, err := os.Open(pdfPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	conf := model.NewDefaultConfiguration()
+	conf.Cmd = model.EXTRACTIMAGES
+	ctx, err := api.ReadValidateAndOptimize(f, conf)
+	if err != nil {
+		return nil, fmt.Errorf("reading pdf %q: %w", pdfPath, err)
+	}
+	return &pdfPages{ctx: ctx}, nil
+}
+
+func (p *pdfPages) Count() int {
+	return p.ctx.PageCount
+}
+
+func (p *pdfPages) Close() error {
+	return nil
+}
+
+// Read returns the image of the page, the pages made of several images are stacked into one png.
+func (p *pdfPages) Read(i int) (string, []byte, error) {
+	if err := checkPage(i, p.ctx.PageCount); err != nil {
+		return "", nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pageNr := i + 1
+	var parts []*model.Image
+	for _, objNr := range pdfPageImages(p.ctx, pageNr) {
+		imageObject := p.ctx.Optimize.ImageObjects[objNr]
+		img, err := pdfcpu.ExtractImage(p.ctx, imageObject.ImageDict, false, imageObject.ResourceNames[i], objNr, false)
+		if err != nil {
+			return "", nil, fmt.Errorf("extracting image %d of page %d: %w", objNr, pageNr, err)
+		}
+		if img != nil {
+			parts = append(parts, img)
+		}
+	}
+
+	switch len(parts) {
+	case 0:
+		return "", nil, fmt.Errorf("page %d has no images", pageNr)
+	case 1:
+		data, err := io.ReadAll(parts[0])
+		return fmt.Sprintf("%04d.%s", pageNr, parts[0].FileType), data, err
+	}
+	var srcs []io.Reader
+	for _, part := range parts {
+		srcs = append(srcs, part)
+	}
+	var buf bytes.Buffer
+	if err := images.StackTo(&buf, srcs); err != nil {
+		return "", nil, fmt.Errorf("combining images of page %d: %w", pageNr, err)
+	}
+	return fmt.Sprintf("%04d.png", pageNr), buf.Bytes(), nil
+}
@@ -0,0 +1,52 @@
+package format
+
+import (
+	"archive/tar"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchivePagesInOrder(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "chapter.cbt")
+	f, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := tar.NewWriter(f)
+	for _, name := range []string{"10.png", "ComicInfo.xml", "2.png", "1.png"} {
+		data := []byte(name)
+		if err := w.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	pages, err := OpenPages(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pages.Close()
+	if pages.Count() != 3 {
+		t.Fatalf("expected: 3 pages, got: %d", pages.Count())
+	}
+	// the pages behind the last page read open the archive again.
+	for _, i := range []int{0, 1, 2, 0, 2, 1} {
+		expected := fmt.Sprintf("%d.png", []int{1, 2, 10}[i])
+		name, data, err := pages.Read(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != expected || string(data) != expected {
+			t.Errorf("expected: %s, got: %s with %s", expected, name, data)
+		}
+	}
+}
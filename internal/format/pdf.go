@@ -5,22 +5,57 @@ import (
 	"github.com/pdfcpu/pdfcpu/pkg/api"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/radam9/manga-tools/internal/images"
 	model2 "github.com/radam9/manga-tools/internal/model"
+	"os"
+	"slices"
+	"strings"
 )
 
-type PDF struct{}
+type PDF struct {
+	Layout  Layout
+	Options PDFOptions
+}
 
-func (p PDF) Save(filePath string, pages []model2.FilePath) error {
+func (p PDF) Save(filePath string, book model2.Book) error {
+	pages := book.PagePaths()
 	if len(pages) == 0 {
 		return nil
 	}
-	imp, conf := DefaultPDFConfig()
-	err := api.ImportImagesFile(pages, filePath, imp, conf)
+	if err := createParentDir(filePath); err != nil {
+		return err
+	}
+
+	// only jpeg and png images are embedded as they are, the other types are transcoded to png.
+	tempDir, err := os.MkdirTemp("", "manga-tools-*")
 	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+	pages, err = images.EnsureTypes(pages, tempDir, images.JPEG, images.PNG)
+	if err != nil {
+		return fmt.Errorf("preparing images: %w", err)
+	}
+
+	conf := DefaultPDFConfig()
+	if err := createPDF(filePath, pages, p.Options, conf); err != nil {
 		return fmt.Errorf("creating pdf from images: %w", err)
 	}
 
-	err = api.OptimizeFile(filePath, filePath, conf)
+	err = editPDF(filePath, conf, func(ctx *model.Context) error {
+		if err := pdfcpu.PropertiesAdd(ctx, pdfProperties(book)); err != nil {
+			return fmt.Errorf("adding pdf properties: %w", err)
+		}
+		setPDFReadingOrder(ctx, book.Manga.Direction, p.Options.TwoPage)
+		if len(book.Chapters) < 2 {
+			return nil
+		}
+		if err := pdfcpu.AddBookmarks(ctx, pdfOutline(book), true); err != nil {
+			return fmt.Errorf("adding pdf outline: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
 		return fmt.Errorf("optimizing pdf file: %w", err)
 	}
@@ -28,15 +63,171 @@ func (p PDF) Save(filePath string, pages []model2.FilePath) error {
 }
 
 func (p PDF) OutputPath(outputDir string, mangaTitle string, volume int, chapterTitle string, chapter float64) string {
-	outputPath := getOutputFilePath(outputDir, mangaTitle, volume, chapter, chapterTitle)
+	outputPath := getOutputFilePath(outputDir, p.Layout, mangaTitle, volume, chapter, chapterTitle)
 	return outputPath + ".pdf"
 }
 
-func DefaultPDFConfig() (*pdfcpu.Import, *model.Configuration) {
-	imp := pdfcpu.DefaultImportConfig()
-	imp.DPI = 300
-	imp.Scale = 1
+func DefaultPDFConfig() *model.Configuration {
 	conf := model.NewDefaultConfiguration()
 	conf.OptimizeDuplicateContentStreams = true
-	return imp, conf
+	return conf
+}
+
+// PDFInfo is the document information of a pdf file.
+type PDFInfo struct {
+	Title     string
+	Author    string
+	Keywords  string
+	PageCount int
+	Outline   []pdfcpu.Bookmark
+	Direction model2.Direction
+}
+
+// ReadPDFInfo reads the document information and the outline of the pdf, without reading its pages.
+func ReadPDFInfo(filePath string) (PDFInfo, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return PDFInfo{}, err
+	}
+	defer f.Close()
+
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	ctx, err := api.ReadAndValidate(f, conf)
+	if err != nil {
+		return PDFInfo{}, fmt.Errorf("reading pdf %q: %w", filePath, err)
+	}
+	outline, err := pdfcpu.Bookmarks(ctx)
+	if err != nil {
+		return PDFInfo{}, fmt.Errorf("reading outline of %q: %w", filePath, err)
+	}
+	info := PDFInfo{
+		Title:     strings.TrimSpace(ctx.Title),
+		Author:    strings.TrimSpace(ctx.Author),
+		Keywords:  strings.TrimSpace(ctx.Keywords),
+		PageCount: ctx.PageCount,
+		Outline:   outline,
+	}
+	if ctx.ViewerPref != nil && ctx.ViewerPref.Direction != nil && *ctx.ViewerPref.Direction == model.R2L {
+		info.Direction = model2.DirectionRTL
+	}
+	return info, nil
+}
+
+// editPDF applies the edit function to the context of the pdf file, and writes it back optimized.
+func editPDF(filePath string, conf *model.Configuration, edit func(ctx *model.Context) error) error {
+	src, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	conf.Cmd = model.OPTIMIZE
+	ctx, err := api.ReadValidateAndOptimize(src, conf)
+	if err != nil {
+		return err
+	}
+	if err := edit(ctx); err != nil {
+		return err
+	}
+
+	tmpPath := filePath + ".tmp"
+	dst, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if err := api.Write(ctx, dst, conf); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	src.Close()
+	return os.Rename(tmpPath, filePath)
+}
+
+// pdfProperties returns the document information of the book.
+func pdfProperties(book model2.Book) map[string]string {
+	var creators []string
+	for _, name := range slices.Concat(book.Manga.Authors, book.Manga.Artists) {
+		if !slices.Contains(creators, name) {
+			creators = append(creators, name)
+		}
+	}
+
+	properties := map[string]string{
+		"Title":    book.Title(),
+		"Author":   strings.Join(creators, ", "),
+		"Subject":  book.Manga.Description,
+		"Keywords": strings.Join(book.Manga.Tags, ", "),
+		"Creator":  "manga-tools",
+	}
+	for key, value := range properties {
+		if value == "" {
+			delete(properties, key)
+		}
+	}
+	return properties
+}
+
+// setPDFReadingOrder makes the viewers turn the pages right to left for right to left books, and display two pages
+// side by side if asked. The first page is displayed alone as the cover, so the following pages are paired right.
+func setPDFReadingOrder(ctx *model.Context, direction model2.Direction, twoPage bool) {
+	if direction == model2.DirectionRTL {
+		r2l := model.R2L
+		if ctx.ViewerPref == nil {
+			ctx.ViewerPref = &model.ViewerPreferences{}
+		}
+		ctx.ViewerPref.Direction = &r2l
+		ctx.XRefTable.BindViewerPreferences()
+	}
+	if twoPage {
+		layout := model.PageLayoutTwoPageRight
+		ctx.RootDict["PageLayout"] = types.Name(layout.String())
+	}
+}
+
+// pdfOutline returns a bookmark per chapter of the book, if the book spans multiple volumes
+// the chapters are grouped under a bookmark per volume.
+func pdfOutline(book model2.Book) []pdfcpu.Bookmark {
+	volumes := map[int]bool{}
+	for _, chapter := range book.Chapters {
+		if chapter.Volume > 0 {
+			volumes[chapter.Volume] = true
+		}
+	}
+	groupByVolume := book.Volume == 0 && len(volumes) > 1
+
+	var bookmarks []pdfcpu.Bookmark
+	pageNumber := 1
+	for i, chapter := range book.Chapters {
+		if len(chapter.Pages) == 0 {
+			continue
+		}
+		label := chapter.Label()
+		if label == "" {
+			label = fmt.Sprintf("Chapter %d", i+1)
+		}
+		bookmark := pdfcpu.Bookmark{Title: label, PageFrom: pageNumber}
+		pageNumber += len(chapter.Pages)
+
+		if !groupByVolume || chapter.Volume == 0 {
+			bookmarks = append(bookmarks, bookmark)
+			continue
+		}
+		volumeTitle := fmt.Sprintf("Volume %d", chapter.Volume)
+		if last := len(bookmarks) - 1; last >= 0 && bookmarks[last].Title == volumeTitle {
+			bookmarks[last].Kids = append(bookmarks[last].Kids, bookmark)
+			continue
+		}
+		bookmarks = append(bookmarks, pdfcpu.Bookmark{
+			Title:    volumeTitle,
+			PageFrom: bookmark.PageFrom,
+			Kids:     []pdfcpu.Bookmark{bookmark},
+		})
+	}
+	return bookmarks
 }
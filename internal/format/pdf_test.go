@@ -0,0 +1,256 @@
+package format
+
+import (
+	"fmt"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/radam9/manga-tools/internal/images"
+	model2 "github.com/radam9/manga-tools/internal/model"
+	"github.com/radam9/manga-tools/internal/testutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSavePDFMetadataAndOutline(t *testing.T) {
+	dir := t.TempDir()
+	newChapter := func(number float64, volume, pagesCount int) model2.Chapter {
+		var pages []model2.FilePath
+		for i := range pagesCount {
+			path := filepath.Join(dir, fmt.Sprintf("chapter%g-page%d.png", number, i))
+			testutil.WritePNG(t, path, 10, 20)
+			pages = append(pages, path)
+		}
+		return model2.Chapter{Number: number, Volume: volume, Pages: model2.NewPagesFromPaths(pages)}
+	}
+
+	book := model2.Book{
+		Manga: model2.Manga{Title: "Slam Dunk", Authors: []string{"Inoue Takehiko"}, Artists: []string{"Inoue Takehiko"}},
+		Chapters: []model2.Chapter{
+			newChapter(1, 1, 2),
+			newChapter(2, 1, 1),
+			newChapter(3, 2, 3),
+		},
+	}
+
+	outputPath := filepath.Join(dir, "bundle.pdf")
+	if err := (PDF{}).Save(outputPath, book); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	ctx, err := api.ReadAndValidate(f, model.NewDefaultConfiguration())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Title != "Slam Dunk" || ctx.Author != "Inoue Takehiko" {
+		t.Errorf("expected title %q and author %q, got: %q, %q", "Slam Dunk", "Inoue Takehiko", ctx.Title, ctx.Author)
+	}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	bookmarks, err := api.Bookmarks(f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bookmarks) != 2 {
+		t.Fatalf("expected 2 volume bookmarks, got: %d", len(bookmarks))
+	}
+	volume2 := bookmarks[1]
+	if volume2.Title != "Volume 2" || volume2.PageFrom != 4 || len(volume2.Kids) != 1 || volume2.Kids[0].Title != "Chapter 3" {
+		t.Errorf("unexpected volume bookmark: %+v", volume2)
+	}
+	if kids := bookmarks[0].Kids; len(kids) != 2 || kids[1].PageFrom != 3 {
+		t.Errorf("unexpected chapter bookmarks: %+v", kids)
+	}
+}
+
+func TestSavePDFRightToLeft(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "page.png")
+	testutil.WritePNG(t, path, 10, 20)
+	book := model2.Book{
+		Manga:    model2.Manga{Title: "Slam Dunk", Direction: model2.DirectionRTL},
+		Chapters: []model2.Chapter{{Number: 1, Pages: model2.NewPagesFromPaths([]model2.FilePath{path})}},
+	}
+
+	outputPath := filepath.Join(dir, "chapter.pdf")
+	if err := (PDF{Options: PDFOptions{TwoPage: true}}).Save(outputPath, book); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	ctx, err := api.ReadAndValidate(f, model.NewDefaultConfiguration())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.ViewerPref == nil || ctx.ViewerPref.Direction == nil || *ctx.ViewerPref.Direction != model.R2L {
+		t.Errorf("expected: R2L direction, got: %+v", ctx.ViewerPref)
+	}
+	if ctx.PageLayout == nil || *ctx.PageLayout != model.PageLayoutTwoPageRight {
+		t.Errorf("expected: TwoPageRight layout, got: %v", ctx.PageLayout)
+	}
+}
+
+func TestSavePDFPageGeometry(t *testing.T) {
+	dir := t.TempDir()
+	portrait := filepath.Join(dir, "portrait.png")
+	testutil.WritePNG(t, portrait, 600, 900)
+	spread := filepath.Join(dir, "spread.png")
+	testutil.WritePNG(t, spread, 1200, 900)
+	book := model2.Book{
+		Manga:    model2.Manga{Title: "Slam Dunk"},
+		Chapters: []model2.Chapter{{Number: 1, Pages: model2.NewPagesFromPaths([]model2.FilePath{portrait, spread})}},
+	}
+	a4, err := ParsePageSize("a4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	white, err := ParseColor("white")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		options  PDFOptions
+		expected []types.Dim
+	}{
+		{name: "page sized to the image at the dpi", options: PDFOptions{DPI: 300}, expected: []types.Dim{{Width: 144, Height: 216}, {Width: 288, Height: 216}}},
+		{name: "margins are added around the image", options: PDFOptions{DPI: 150, Margin: 10}, expected: []types.Dim{{Width: 308, Height: 452}, {Width: 596, Height: 452}}},
+		{name: "fixed page size turned for spreads", options: PDFOptions{PageSize: a4, Fit: PageFitFill, Margin: 20, Background: white}, expected: []types.Dim{{Width: 595, Height: 842}, {Width: 842, Height: 595}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := filepath.Join(dir, "chapter.pdf")
+			if err := (PDF{Options: tt.options}).Save(outputPath, book); err != nil {
+				t.Fatal(err)
+			}
+			dims, err := api.PageDimsFile(outputPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(dims) != len(tt.expected) {
+				t.Fatalf("expected: %d pages, got: %d", len(tt.expected), len(dims))
+			}
+			for i, dim := range dims {
+				if math.Abs(dim.Width-tt.expected[i].Width) > 0.5 || math.Abs(dim.Height-tt.expected[i].Height) > 0.5 {
+					t.Errorf("expected: %v, got: %v", tt.expected[i], dim)
+				}
+			}
+		})
+	}
+
+	t.Run("existing pdf pages are placed on the page size", func(t *testing.T) {
+		outputPath := filepath.Join(dir, "merged.pdf")
+		if err := (PDF{}).Save(outputPath, book); err != nil {
+			t.Fatal(err)
+		}
+		letter, err := ParsePageSize("Letter")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ApplyPDFOptions(outputPath, PDFOptions{PageSize: letter, Margin: 10}); err != nil {
+			t.Fatal(err)
+		}
+		dims, err := api.PageDimsFile(outputPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []types.Dim{{Width: 612, Height: 792}, {Width: 792, Height: 612}}
+		for i, dim := range dims {
+			if dim != expected[i] {
+				t.Errorf("expected: %v, got: %v", expected[i], dim)
+			}
+		}
+	})
+}
+
+func TestExtractPDF(t *testing.T) {
+	dir := t.TempDir()
+	var pages []model2.FilePath
+	for i, size := range [][2]int{{60, 90}, {120, 90}} {
+		path := filepath.Join(dir, fmt.Sprintf("page%d.png", i))
+		testutil.WritePNG(t, path, size[0], size[1])
+		pages = append(pages, path)
+	}
+	book := model2.Book{
+		Manga:    model2.Manga{Title: "Slam Dunk"},
+		Chapters: []model2.Chapter{{Number: 1, Pages: model2.NewPagesFromPaths(pages)}},
+	}
+	if err := (PDF{}).Save(filepath.Join(dir, "chapter.pdf"), book); err != nil {
+		t.Fatal(err)
+	}
+
+	extracted, err := ExtractPDF(t.TempDir(), filepath.Join(dir, "chapter.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(extracted) != len(pages) {
+		t.Fatalf("expected: %d pages, got: %d", len(pages), len(extracted))
+	}
+	for i, path := range extracted {
+		_, expectedWidth, expectedHeight := images.Stats(pages[i])
+		if _, width, height := images.Stats(path); width != expectedWidth || height != expectedHeight {
+			t.Errorf("expected: %dx%d, got: %dx%d", expectedWidth, expectedHeight, width, height)
+		}
+	}
+}
+
+func TestMergePDFs(t *testing.T) {
+	dir := t.TempDir()
+	newPDF := func(name, title string, chaptersCount int) string {
+		var chapters []model2.Chapter
+		for i := range chaptersCount {
+			path := filepath.Join(dir, fmt.Sprintf("%s-%d.png", name, i))
+			testutil.WritePNG(t, path, 10, 20)
+			chapter := model2.Chapter{Pages: model2.NewPagesFromPaths([]model2.FilePath{path, path})}
+			if title != "" {
+				chapter.Number = float64(i + 1)
+			}
+			chapters = append(chapters, chapter)
+		}
+		outputPath := filepath.Join(dir, name+".pdf")
+		if err := (PDF{}).Save(outputPath, model2.Book{Manga: model2.Manga{Title: title}, Chapters: chapters}); err != nil {
+			t.Fatal(err)
+		}
+		return outputPath
+	}
+	inputs := []string{newPDF("volume-1", "Slam Dunk", 2), newPDF("volume-2", "", 1)}
+
+	outputPath := filepath.Join(dir, "merged.pdf")
+	if err := MergePDFs(inputs, outputPath); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	bookmarks, err := api.Bookmarks(f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bookmarks) != 2 {
+		t.Fatalf("expected: 2 bookmarks, got: %+v", bookmarks)
+	}
+	if first := bookmarks[0]; first.Title != "Slam Dunk" || len(first.Kids) != 2 || first.Kids[1].Title != "Chapter 2" || first.Kids[1].PageFrom != 3 {
+		t.Errorf("unexpected bookmark of the first input: %+v", first)
+	}
+	if second := bookmarks[1]; second.Title != "volume-2" || second.PageFrom != 5 || len(second.Kids) != 0 {
+		t.Errorf("unexpected bookmark of the second input: %+v", second)
+	}
+}
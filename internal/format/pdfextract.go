@@ -0,0 +1,131 @@
+package format
+
+import (
+	"fmt"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/radam9/manga-tools/internal/images"
+	model2 "github.com/radam9/manga-tools/internal/model"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// ExtractPDF extracts the page images of the pdf into the output dir, and returns their paths in page order.
+// Pages made of several images (e.g. scans cut into strips) are stacked into one image, and pages without
+// images (text or vector drawings) are skipped with a warning as they can't be rendered.
+// The images are named by their page, so every pdf needs its own output dir.
+func ExtractPDF(outputDir, pdfPath string) ([]model2.FilePath, error) {
+	f, err := os.Open(pdfPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	conf := model.NewDefaultConfiguration()
+	conf.Cmd = model.EXTRACTIMAGES
+	ctx, err := api.ReadValidateAndOptimize(f, conf)
+	if err != nil {
+		return nil, fmt.Errorf("reading pdf %q: %w", pdfPath, err)
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("creating output directory %q: %w", outputDir, err)
+	}
+
+	var pages []model2.FilePath
+	for pageNr := 1; pageNr <= ctx.PageCount; pageNr++ {
+		parts, err := extractPDFPageImages(ctx, pageNr, outputDir)
+		if err != nil {
+			return nil, fmt.Errorf("extracting images of page %d of %q: %w", pageNr, pdfPath, err)
+		}
+
+		switch len(parts) {
+		case 0:
+			slog.Warn("skipping pdf page without images", "path", pdfPath, "page", pageNr)
+		case 1:
+			pages = append(pages, parts[0])
+		default:
+			slog.Warn("stacking the images of a pdf page made of several images", "path", pdfPath, "page", pageNr, "images", len(parts))
+			page, err := images.Stack(parts, outputDir)
+			if err != nil {
+				return nil, fmt.Errorf("combining images of page %d of %q: %w", pageNr, pdfPath, err)
+			}
+			pages = append(pages, page)
+		}
+	}
+	return pages, nil
+}
+
+// pdfPageImages returns the object numbers of the images drawn on the page, in object order.
+// The soft masks and stencil masks of the images aren't page content, they're left out.
+func pdfPageImages(ctx *model.Context, pageNr int) []int {
+	objNrs := pdfcpu.ImageObjNrs(ctx, pageNr)
+	slices.Sort(objNrs)
+
+	masks := map[int]bool{}
+	for _, objNr := range objNrs {
+		imageDict := ctx.Optimize.ImageObjects[objNr].ImageDict
+		for _, key := range []string{"SMask", "Mask"} {
+			if ref := imageDict.IndirectRefEntry(key); ref != nil {
+				masks[ref.ObjectNumber.Value()] = true
+			}
+		}
+	}
+
+	var result []int
+	for _, objNr := range objNrs {
+		if masks[objNr] {
+			continue
+		}
+		if isMask := ctx.Optimize.ImageObjects[objNr].ImageDict.BooleanEntry("ImageMask"); isMask != nil && *isMask {
+			continue
+		}
+		result = append(result, objNr)
+	}
+	return result
+}
+
+// extractPDFPageImages writes the images drawn on the page into the output dir.
+func extractPDFPageImages(ctx *model.Context, pageNr int, outputDir string) ([]model2.FilePath, error) {
+	var paths []model2.FilePath
+	for _, objNr := range pdfPageImages(ctx, pageNr) {
+		imageObject := ctx.Optimize.ImageObjects[objNr]
+		img, err := pdfcpu.ExtractImage(ctx, imageObject.ImageDict, false, imageObject.ResourceNames[pageNr-1], objNr, false)
+		if err != nil {
+			slog.Warn("skipping unsupported pdf image", "page", pageNr, "object", objNr, "error", err)
+			continue
+		}
+		if img == nil {
+			continue
+		}
+
+		path, err := writePDFImage(img, outputDir, fmt.Sprintf("%04d-%d", pageNr, objNr))
+		if err != nil {
+			slog.Warn("skipping unsupported pdf image", "page", pageNr, "object", objNr, "error", err)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// writePDFImage writes the extracted image, and names it after the image type detected from its content.
+func writePDFImage(img *model.Image, outputDir, name string) (model2.FilePath, error) {
+	tmpPath := filepath.Join(outputDir, name+".tmp")
+	if err := extractFile(img, tmpPath); err != nil {
+		return "", err
+	}
+	imageType, err := images.Detect(tmpPath)
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	path := filepath.Join(outputDir, name+imageType.Extension())
+	if err := os.Rename(tmpPath, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
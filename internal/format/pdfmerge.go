@@ -0,0 +1,69 @@
+package format
+
+import (
+	"fmt"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"path/filepath"
+	"strings"
+)
+
+// MergePDFs merges the pdf files into one, with an outline entry per file labelled with its title, or its name if it
+// has none. The outline of every file is nested under its entry.
+func MergePDFs(inputs []string, outputPath string) error {
+	var bookmarks []pdfcpu.Bookmark
+	pageNumber := 1
+	for _, input := range inputs {
+		bookmark, pageCount, err := pdfInputBookmark(input, pageNumber)
+		if err != nil {
+			return fmt.Errorf("reading outline of %q: %w", input, err)
+		}
+		bookmarks = append(bookmarks, bookmark)
+		pageNumber += pageCount
+	}
+
+	conf := DefaultPDFConfig()
+	conf.CreateBookmarks = false
+	if err := api.MergeCreateFile(inputs, outputPath, false, conf); err != nil {
+		return err
+	}
+
+	return editPDF(outputPath, DefaultPDFConfig(), func(ctx *model.Context) error {
+		if err := pdfcpu.AddBookmarks(ctx, bookmarks, true); err != nil {
+			return fmt.Errorf("adding pdf outline: %w", err)
+		}
+		return nil
+	})
+}
+
+// pdfInputBookmark returns the outline entry of a merged file starting at the given page, and its page count.
+func pdfInputBookmark(path string, pageFrom int) (pdfcpu.Bookmark, int, error) {
+	info, err := ReadPDFInfo(path)
+	if err != nil {
+		return pdfcpu.Bookmark{}, 0, err
+	}
+
+	title := info.Title
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	bookmark := pdfcpu.Bookmark{Title: title, PageFrom: pageFrom, Kids: shiftBookmarks(info.Outline, pageFrom-1)}
+	return bookmark, info.PageCount, nil
+}
+
+// shiftBookmarks moves the bookmarks by the given number of pages, and drops the links to their parents
+// as they are nested under a new entry.
+func shiftBookmarks(bookmarks []pdfcpu.Bookmark, offset int) []pdfcpu.Bookmark {
+	result := make([]pdfcpu.Bookmark, 0, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		bookmark.PageFrom += offset
+		if bookmark.PageThru > 0 {
+			bookmark.PageThru += offset
+		}
+		bookmark.Parent = nil
+		bookmark.Kids = shiftBookmarks(bookmark.Kids, offset)
+		result = append(result, bookmark)
+	}
+	return result
+}
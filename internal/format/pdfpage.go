@@ -0,0 +1,331 @@
+package format
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/color"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// PageFit is how the content is placed on pages of a fixed size.
+type PageFit string
+
+const (
+	// PageFitFit scales the content to fit in the page, leaving blank bands around it.
+	PageFitFit PageFit = "fit"
+	// PageFitFill scales the content to cover the page, cutting what overflows.
+	PageFitFill PageFit = "fill"
+	// PageFitCenter keeps the content at its size, it's only scaled down if it doesn't fit in the page.
+	PageFitCenter PageFit = "center"
+)
+
+var PageFits = []PageFit{PageFitFit, PageFitFill, PageFitCenter}
+
+func ParsePageFit(s string) (PageFit, error) {
+	for _, fit := range PageFits {
+		if strings.EqualFold(s, string(fit)) {
+			return fit, nil
+		}
+	}
+	return "", fmt.Errorf("unknown page fit %q, must be one of %v", s, PageFits)
+}
+
+const (
+	DefaultPDFDPI       = 300
+	pointsPerInch       = 72
+	pointsPerMillimeter = pointsPerInch / 25.4
+)
+
+// PDFOptions are the settings of the pdf outputs.
+type PDFOptions struct {
+	// TwoPage opens the pdf with two pages side by side, the cover alone.
+	TwoPage bool
+	// DPI is the resolution the images are printed at, it sets their size on the pages. Zero uses DefaultPDFDPI.
+	DPI int
+	// PageSize is the size of every page in points, nil sizes every page to its content.
+	// The pages are turned to landscape for landscape content, e.g. the double-page spreads.
+	PageSize *types.Dim
+	// Fit is how the content is placed on pages of a fixed size, empty fits it.
+	Fit PageFit
+	// Margin is the blank space around the content, in points.
+	Margin float64
+	// Background is the colour of the page behind the content, nil leaves it transparent.
+	Background *color.SimpleColor
+}
+
+// ParsePageSize parses the paper sizes (A4, Letter, B5...) and the custom sizes in millimeters, e.g. 130x180.
+// An empty size returns nil.
+func ParsePageSize(s string) (*types.Dim, error) {
+	if s == "" {
+		return nil, nil
+	}
+	for name, dim := range types.PaperSize {
+		if strings.EqualFold(name, s) {
+			return &types.Dim{Width: dim.Width, Height: dim.Height}, nil
+		}
+	}
+
+	w, h, found := strings.Cut(strings.ToLower(strings.TrimSuffix(s, "mm")), "x")
+	width, widthErr := strconv.ParseFloat(w, 64)
+	height, heightErr := strconv.ParseFloat(h, 64)
+	if !found || widthErr != nil || heightErr != nil || width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("unknown page size %q, must be a paper size like A4, Letter or B5, or WIDTHxHEIGHT in millimeters", s)
+	}
+	return &types.Dim{Width: width * pointsPerMillimeter, Height: height * pointsPerMillimeter}, nil
+}
+
+// ParseColor parses colour names (white, black...), #RRGGBB hex codes and "R G B" intensities from 0 to 1.
+// An empty colour returns nil.
+func ParseColor(s string) (*color.SimpleColor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	c, err := color.ParseColor(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid colour %q: %w", s, err)
+	}
+	return &c, nil
+}
+
+// MillimetersToPoints converts lengths in millimeters to pdf points.
+func MillimetersToPoints(mm float64) float64 {
+	return mm * pointsPerMillimeter
+}
+
+func (o PDFOptions) dpi() int {
+	if o.DPI <= 0 {
+		return DefaultPDFDPI
+	}
+	return o.DPI
+}
+
+// changesPages returns true if the options change the geometry of existing pdf pages.
+func (o PDFOptions) changesPages() bool {
+	return o.PageSize != nil || o.Margin > 0 || o.Background != nil
+}
+
+// pdfPlacement is where content is drawn on its page.
+type pdfPlacement struct {
+	page types.Rectangle
+	// clip is the area of the page inside the margins, what overflows it is cut.
+	clip  types.Rectangle
+	scale float64
+	// x and y are the lower left corner of the scaled content.
+	x, y float64
+}
+
+// place returns the placement of content of the given size in points.
+func (o PDFOptions) place(width, height float64) pdfPlacement {
+	if o.PageSize == nil {
+		return pdfPlacement{
+			page:  *types.RectForDim(width+2*o.Margin, height+2*o.Margin),
+			clip:  *types.NewRectangle(o.Margin, o.Margin, o.Margin+width, o.Margin+height),
+			scale: 1,
+			x:     o.Margin,
+			y:     o.Margin,
+		}
+	}
+
+	pageWidth, pageHeight := o.PageSize.Width, o.PageSize.Height
+	if (width > height && pageWidth < pageHeight) || (width < height && pageWidth > pageHeight) {
+		pageWidth, pageHeight = pageHeight, pageWidth
+	}
+	boxWidth := math.Max(1, pageWidth-2*o.Margin)
+	boxHeight := math.Max(1, pageHeight-2*o.Margin)
+
+	scale := math.Min(boxWidth/width, boxHeight/height)
+	switch o.Fit {
+	case PageFitFill:
+		scale = math.Max(boxWidth/width, boxHeight/height)
+	case PageFitCenter:
+		scale = math.Min(1, scale)
+	}
+
+	marginX := (pageWidth - boxWidth) / 2
+	marginY := (pageHeight - boxHeight) / 2
+	return pdfPlacement{
+		page:  *types.RectForDim(pageWidth, pageHeight),
+		clip:  *types.NewRectangle(marginX, marginY, marginX+boxWidth, marginY+boxHeight),
+		scale: scale,
+		x:     marginX + (boxWidth-width*scale)/2,
+		y:     marginY + (boxHeight-height*scale)/2,
+	}
+}
+
+// prefix returns the content drawn before the content of the page: the background, and a graphics state clipped to
+// the margins with the given transformation matrix. The graphics state is closed by suffix.
+func (p pdfPlacement) prefix(background *color.SimpleColor, sx, sy, tx, ty float64) []byte {
+	var buf bytes.Buffer
+	if background != nil {
+		fmt.Fprintf(&buf, "q %.4f %.4f %.4f rg %s re f Q\n", background.R, background.G, background.B, rectangleOperands(p.page))
+	}
+	fmt.Fprintf(&buf, "q %s re W n %.5f 0 0 %.5f %.5f %.5f cm\n", rectangleOperands(p.clip), sx, sy, tx, ty)
+	return buf.Bytes()
+}
+
+func (p pdfPlacement) suffix() []byte {
+	return []byte("\nQ\n")
+}
+
+func rectangleOperands(r types.Rectangle) string {
+	return fmt.Sprintf("%.5f %.5f %.5f %.5f", r.LL.X, r.LL.Y, r.Width(), r.Height())
+}
+
+// createPDF writes a pdf with one page per image.
+func createPDF(filePath string, imagePaths []string, options PDFOptions, conf *model.Configuration) error {
+	conf.Cmd = model.IMPORTIMAGES
+	ctx, err := pdfcpu.CreateContextWithXRefTable(conf, types.PaperSize["A4"])
+	if err != nil {
+		return err
+	}
+	pagesIndRef, err := ctx.Pages()
+	if err != nil {
+		return err
+	}
+	pagesDict, err := ctx.DereferenceDict(*pagesIndRef)
+	if err != nil {
+		return err
+	}
+
+	for _, imagePath := range imagePaths {
+		indRef, err := newPDFImagePage(ctx.XRefTable, imagePath, pagesIndRef, options)
+		if err != nil {
+			return fmt.Errorf("adding image %q: %w", imagePath, err)
+		}
+		if err := ctx.SetValid(*indRef); err != nil {
+			return err
+		}
+		if err := model.AppendPageTree(indRef, 1, pagesDict); err != nil {
+			return err
+		}
+		ctx.PageCount++
+	}
+	return api.WriteContextFile(ctx, filePath)
+}
+
+func newPDFImagePage(xRefTable *model.XRefTable, imagePath string, parent *types.IndirectRef, options PDFOptions) (*types.IndirectRef, error) {
+	f, err := os.Open(imagePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	imgIndRef, w, h, err := model.CreateImageResource(xRefTable, bufio.NewReader(f), false, false)
+	if err != nil {
+		return nil, err
+	}
+	resIndRef, err := xRefTable.IndRefForNewObject(types.Dict(map[string]types.Object{
+		"ProcSet": types.NewNameArray("PDF", "ImageB", "ImageC", "ImageI"),
+		"XObject": types.Dict(map[string]types.Object{"Im0": *imgIndRef}),
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	pointsPerPixel := float64(pointsPerInch) / float64(options.dpi())
+	width, height := float64(w)*pointsPerPixel, float64(h)*pointsPerPixel
+	placement := options.place(width, height)
+
+	content := placement.prefix(options.Background, width*placement.scale, height*placement.scale, placement.x, placement.y)
+	content = append(content, "/Im0 Do"...)
+	content = append(content, placement.suffix()...)
+	contentsIndRef, err := newPDFStream(xRefTable, content)
+	if err != nil {
+		return nil, err
+	}
+
+	return xRefTable.IndRefForNewObject(types.Dict(map[string]types.Object{
+		"Type":      types.Name("Page"),
+		"Parent":    *parent,
+		"MediaBox":  placement.page.Array(),
+		"Resources": *resIndRef,
+		"Contents":  *contentsIndRef,
+	}))
+}
+
+func newPDFStream(xRefTable *model.XRefTable, content []byte) (*types.IndirectRef, error) {
+	sd, err := xRefTable.NewStreamDictForBuf(content)
+	if err != nil {
+		return nil, err
+	}
+	if err := sd.Encode(); err != nil {
+		return nil, err
+	}
+	return xRefTable.IndRefForNewObject(*sd)
+}
+
+// ApplyPDFOptions places the pages of an existing pdf following the options, and sets its page layout.
+func ApplyPDFOptions(filePath string, options PDFOptions) error {
+	if !options.changesPages() && !options.TwoPage {
+		return nil
+	}
+	return editPDF(filePath, DefaultPDFConfig(), func(ctx *model.Context) error {
+		if options.changesPages() {
+			if err := placePDFPages(ctx, options); err != nil {
+				return fmt.Errorf("placing pdf pages: %w", err)
+			}
+		}
+		setPDFReadingOrder(ctx, "", options.TwoPage)
+		return nil
+	})
+}
+
+// placePDFPages wraps the content of every page in the transformation placing it on its new page.
+// The rotation of the pages is kept, the new pages have the orientation of their unrotated content.
+func placePDFPages(ctx *model.Context, options PDFOptions) error {
+	for pageNumber := 1; pageNumber <= ctx.PageCount; pageNumber++ {
+		pageDict, _, inherited, err := ctx.PageDict(pageNumber, false)
+		if err != nil {
+			return err
+		}
+		if pageDict == nil || inherited.MediaBox == nil {
+			return fmt.Errorf("page %d has no media box", pageNumber)
+		}
+		box := inherited.MediaBox
+		if inherited.CropBox != nil {
+			box = inherited.CropBox
+		}
+
+		placement := options.place(box.Width(), box.Height())
+		prefix := placement.prefix(options.Background, placement.scale, placement.scale, placement.x-box.LL.X*placement.scale, placement.y-box.LL.Y*placement.scale)
+		prefixIndRef, err := newPDFStream(ctx.XRefTable, prefix)
+		if err != nil {
+			return err
+		}
+		suffixIndRef, err := newPDFStream(ctx.XRefTable, placement.suffix())
+		if err != nil {
+			return err
+		}
+
+		contents := types.Array{*prefixIndRef}
+		if obj, found := pageDict.Find("Contents"); found {
+			resolved, err := ctx.Dereference(obj)
+			if err != nil {
+				return err
+			}
+			if array, ok := resolved.(types.Array); ok {
+				contents = append(contents, array...)
+			} else {
+				contents = append(contents, obj)
+			}
+		}
+		contents = append(contents, *suffixIndRef)
+
+		pageDict["Contents"] = contents
+		// the crop box can be inherited from the page tree, it's set on the page to override it.
+		pageDict["MediaBox"] = placement.page.Array()
+		pageDict["CropBox"] = placement.page.Array()
+		for _, key := range []string{"BleedBox", "TrimBox", "ArtBox"} {
+			delete(pageDict, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package format
+
+import (
+	"fmt"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	model2 "github.com/radam9/manga-tools/internal/model"
+	"github.com/radam9/manga-tools/internal/ranges"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PageRange is a part of a split file, the pages are numbered from 1 and both ends are included.
+type PageRange struct {
+	From  int
+	Thru  int
+	Title string
+	// Outline is the outline of the part, with pages numbered from the start of the part.
+	Outline []pdfcpu.Bookmark
+}
+
+// EveryPages splits the pages in parts of n pages, the last part holds the remaining pages.
+func EveryPages(pageCount, n int) []PageRange {
+	var parts []PageRange
+	for from := 1; from <= pageCount; from += n {
+		parts = append(parts, PageRange{From: from, Thru: min(from+n-1, pageCount)})
+	}
+	return numberParts(parts)
+}
+
+// PagesBySize splits the pages in parts whose estimated size stays under maxSize, given the size of every page
+// and the size every part has on top of its pages. A page bigger than maxSize is a part on its own.
+func PagesBySize(sizes []int64, overhead, maxSize int64) []PageRange {
+	var parts []PageRange
+	var part PageRange
+	var size int64
+	for i, pageSize := range sizes {
+		if part.From > 0 && overhead+size+pageSize > maxSize {
+			parts = append(parts, part)
+			part, size = PageRange{}, 0
+		}
+		if part.From == 0 {
+			part.From = i + 1
+		}
+		part.Thru = i + 1
+		size += pageSize
+		if overhead+pageSize > maxSize {
+			slog.Warn("page is bigger than the maximum size", "page", i+1, "size", pageSize)
+		}
+	}
+	if part.From > 0 {
+		parts = append(parts, part)
+	}
+	return numberParts(parts)
+}
+
+// ParsePageRanges parses page ranges like `1-40,41-90`, a single number being a range of one page.
+func ParsePageRanges(s string, pageCount int) ([]PageRange, error) {
+	rngs, err := ranges.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("parsing page ranges %q: %w", s, err)
+	}
+	if len(rngs) == 0 {
+		return nil, fmt.Errorf("no page ranges in %q", s)
+	}
+
+	var parts []PageRange
+	for _, rng := range rngs {
+		if rng.Start != math.Trunc(rng.Start) || rng.End != math.Trunc(rng.End) {
+			return nil, fmt.Errorf("page range %g-%g must be whole pages", rng.Start, rng.End)
+		}
+		part := PageRange{From: int(rng.Start), Thru: int(rng.End)}
+		if part.From < 1 || part.Thru > pageCount {
+			return nil, fmt.Errorf("page range %d-%d is out of the %d pages", part.From, part.Thru, pageCount)
+		}
+		parts = append(parts, part)
+	}
+	return numberParts(parts), nil
+}
+
+func numberParts(parts []PageRange) []PageRange {
+	for i := range parts {
+		parts[i].Title = fmt.Sprintf("part %d", i+1)
+	}
+	return parts
+}
+
+// BookOutline returns a part per chapter of the book, titled with the chapter label.
+func BookOutline(book model2.Book) []PageRange {
+	var parts []PageRange
+	from := 1
+	for i, chapter := range book.Chapters {
+		if len(chapter.Pages) == 0 {
+			continue
+		}
+		title := chapter.Label()
+		if title == "" {
+			title = fmt.Sprintf("Chapter %d", i+1)
+		}
+		parts = append(parts, PageRange{From: from, Thru: from + len(chapter.Pages) - 1, Title: title})
+		from += len(chapter.Pages)
+	}
+	return parts
+}
+
+// SplitBook returns the book of every part, the chapters cut by a part keep their metadata in both parts.
+func SplitBook(book model2.Book, parts []PageRange) []model2.Book {
+	var books []model2.Book
+	for _, part := range parts {
+		partBook := model2.Book{Manga: book.Manga, Volume: book.Volume}
+		pageNumber := 1
+		for _, chapter := range book.Chapters {
+			var pages []model2.Page
+			for _, page := range chapter.Pages {
+				if pageNumber >= part.From && pageNumber <= part.Thru {
+					pages = append(pages, page)
+				}
+				pageNumber++
+			}
+			if len(pages) > 0 {
+				chapter.Pages = pages
+				partBook.Chapters = append(partBook.Chapters, chapter)
+			}
+		}
+		books = append(books, partBook)
+	}
+	return books
+}
+
+// SplitPDF is a pdf file opened to be split in parts.
+type SplitPDF struct {
+	path string
+	ctx  *model.Context
+}
+
+func OpenSplitPDF(path string) (*SplitPDF, error) {
+	ctx, err := readSplitPDF(path)
+	if err != nil {
+		return nil, err
+	}
+	return &SplitPDF{path: path, ctx: ctx}, nil
+}
+
+func readSplitPDF(path string) (*model.Context, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	conf := DefaultPDFConfig()
+	conf.Cmd = model.TRIM
+	ctx, err := api.ReadValidateAndOptimize(f, conf)
+	if err != nil {
+		return nil, fmt.Errorf("reading pdf %q: %w", path, err)
+	}
+	return ctx, nil
+}
+
+func (s *SplitPDF) PageCount() int {
+	return s.ctx.PageCount
+}
+
+// PageSizes returns the estimated size of every page, the size of its images, and the size of the rest of the file.
+func (s *SplitPDF) PageSizes() ([]int64, int64) {
+	var sizes []int64
+	var total int64
+	for pageNr := 1; pageNr <= s.ctx.PageCount; pageNr++ {
+		var size int64
+		for _, objNr := range pdfcpu.ImageObjNrs(s.ctx, pageNr) {
+			imageDict := s.ctx.Optimize.ImageObjects[objNr].ImageDict
+			if imageDict.StreamLength != nil {
+				size += *imageDict.StreamLength
+			} else {
+				size += int64(len(imageDict.Raw))
+			}
+		}
+		sizes = append(sizes, size)
+		total += size
+	}
+	return sizes, max(s.ctx.Read.FileSize-total, 0)
+}
+
+// Outline returns a part per top level entry of the outline, with the entries nested under it. The pages before the
+// first entry, e.g. the cover, are a part named after the pdf.
+func (s *SplitPDF) Outline() ([]PageRange, error) {
+	bookmarks, err := pdfcpu.Bookmarks(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading pdf outline: %w", err)
+	}
+
+	var parts []PageRange
+	for i, bookmark := range bookmarks {
+		thru := s.ctx.PageCount
+		if i+1 < len(bookmarks) {
+			thru = bookmarks[i+1].PageFrom - 1
+		}
+		if thru < bookmark.PageFrom {
+			// entries pointing to the same page, or out of order.
+			continue
+		}
+		parts = append(parts, PageRange{
+			From:    bookmark.PageFrom,
+			Thru:    thru,
+			Title:   strings.TrimSpace(bookmark.Title),
+			Outline: shiftBookmarks(bookmark.Kids, 1-bookmark.PageFrom),
+		})
+	}
+	if len(parts) > 0 && parts[0].From > 1 {
+		name := strings.TrimSuffix(filepath.Base(s.path), filepath.Ext(s.path))
+		parts = append([]PageRange{{From: 1, Thru: parts[0].From - 1, Title: name}}, parts...)
+	}
+	return parts, nil
+}
+
+// Save writes the pages of the part into a new pdf, with the metadata and reading order of the split pdf.
+func (s *SplitPDF) Save(filePath string, part PageRange) error {
+	if err := createParentDir(filePath); err != nil {
+		return err
+	}
+	var pageNrs []int
+	for pageNr := part.From; pageNr <= part.Thru; pageNr++ {
+		pageNrs = append(pageNrs, pageNr)
+	}
+	// the pages are copied by moving their objects, so every part is extracted from a fresh read of the file.
+	src, err := readSplitPDF(s.path)
+	if err != nil {
+		return err
+	}
+	ctx, err := pdfcpu.ExtractPages(src, pageNrs, false)
+	if err != nil {
+		return fmt.Errorf("extracting pages %d-%d: %w", part.From, part.Thru, err)
+	}
+	if err := api.WriteContextFile(ctx, filePath); err != nil {
+		return fmt.Errorf("writing pdf: %w", err)
+	}
+
+	return editPDF(filePath, DefaultPDFConfig(), func(ctx *model.Context) error {
+		if err := pdfcpu.PropertiesAdd(ctx, s.partProperties(part)); err != nil {
+			return fmt.Errorf("adding pdf properties: %w", err)
+		}
+		if s.ctx.ViewerPref != nil && s.ctx.ViewerPref.Direction != nil {
+			ctx.ViewerPref = &model.ViewerPreferences{Direction: s.ctx.ViewerPref.Direction}
+			ctx.XRefTable.BindViewerPreferences()
+		}
+		if layout, ok := s.ctx.RootDict["PageLayout"].(types.Name); ok {
+			ctx.RootDict["PageLayout"] = layout
+		}
+		if len(part.Outline) == 0 {
+			return nil
+		}
+		if err := pdfcpu.AddBookmarks(ctx, part.Outline, true); err != nil {
+			return fmt.Errorf("adding pdf outline: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *SplitPDF) partProperties(part PageRange) map[string]string {
+	title := strings.TrimSpace(s.ctx.Title)
+	if title == "" {
+		title = part.Title
+	} else if part.Title != "" {
+		title = fmt.Sprintf("%s - %s", title, part.Title)
+	}
+	properties := map[string]string{
+		"Title":    title,
+		"Author":   s.ctx.Author,
+		"Subject":  s.ctx.Subject,
+		"Keywords": s.ctx.Keywords,
+		"Creator":  s.ctx.Creator,
+	}
+	for key, value := range properties {
+		if value == "" {
+			delete(properties, key)
+		}
+	}
+	return properties
+}
@@ -0,0 +1,118 @@
+package format
+
+import (
+	"fmt"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	model2 "github.com/radam9/manga-tools/internal/model"
+	"github.com/radam9/manga-tools/internal/testutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func pageRanges(parts []PageRange) string {
+	var result string
+	for _, part := range parts {
+		result += fmt.Sprintf("%d-%d,", part.From, part.Thru)
+	}
+	return result
+}
+
+func TestPageRanges(t *testing.T) {
+	if got := pageRanges(EveryPages(10, 4)); got != "1-4,5-8,9-10," {
+		t.Errorf("expected: 1-4,5-8,9-10, got: %s", got)
+	}
+	if got := pageRanges(PagesBySize([]int64{40, 40, 30, 90, 10}, 10, 100)); got != "1-2,3-3,4-4,5-5," {
+		t.Errorf("expected: 1-2,3-3,4-4,5-5, got: %s", got)
+	}
+
+	parts, err := ParsePageRanges("1-40,41-90", 90)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pageRanges(parts); got != "1-40,41-90," || parts[1].Title != "part 2" {
+		t.Errorf("expected: 1-40,41-90, got: %s %+v", got, parts)
+	}
+	for _, s := range []string{"1-91", "0-2", "1.5-3"} {
+		if _, err := ParsePageRanges(s, 90); err == nil {
+			t.Errorf("expected: error for %q, got: nil", s)
+		}
+	}
+}
+
+func TestSplitPDFByOutline(t *testing.T) {
+	dir := t.TempDir()
+	var chapters []model2.Chapter
+	for i := range 3 {
+		path := filepath.Join(dir, fmt.Sprintf("page%d.png", i))
+		testutil.WritePNG(t, path, 10, 20)
+		chapters = append(chapters, model2.Chapter{Number: float64(i + 1), Volume: i/2 + 1, Pages: model2.NewPagesFromPaths([]model2.FilePath{path, path})})
+	}
+	bundlePath := filepath.Join(dir, "bundle.pdf")
+	book := model2.Book{Manga: model2.Manga{Title: "Slam Dunk", Authors: []string{"Inoue Takehiko"}, Direction: model2.DirectionRTL}, Chapters: chapters}
+	if err := (PDF{}).Save(bundlePath, book); err != nil {
+		t.Fatal(err)
+	}
+
+	pdf, err := OpenSplitPDF(bundlePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts, err := pdf.Outline()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pageRanges(parts); got != "1-4,5-6," || parts[0].Title != "Volume 1" || len(parts[0].Outline) != 2 {
+		t.Fatalf("expected: a part per volume, got: %+v", parts)
+	}
+
+	partPath := filepath.Join(dir, "volume-1.pdf")
+	if err := pdf.Save(partPath, parts[0]); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(partPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	ctx, err := api.ReadAndValidate(f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.PageCount != 4 || ctx.Title != "Slam Dunk - Volume 1" || ctx.Author != "Inoue Takehiko" {
+		t.Errorf("expected: 4 pages with the metadata, got: %d pages, %q, %q", ctx.PageCount, ctx.Title, ctx.Author)
+	}
+	if ctx.ViewerPref == nil || ctx.ViewerPref.Direction == nil {
+		t.Errorf("expected: the reading direction, got: %+v", ctx.ViewerPref)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	bookmarks, err := api.Bookmarks(f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bookmarks) != 2 || bookmarks[1].Title != "Chapter 2" || bookmarks[1].PageFrom != 3 {
+		t.Errorf("expected: the chapters of the volume, got: %+v", bookmarks)
+	}
+
+	t.Run("pages before the outline", func(t *testing.T) {
+		coverPath := filepath.Join(dir, "with-cover.pdf")
+		outline := []pdfcpu.Bookmark{{Title: "Chapter 1", PageFrom: 3}, {Title: "Chapter 2", PageFrom: 5}}
+		if err := api.AddBookmarksFile(bundlePath, coverPath, outline, true, nil); err != nil {
+			t.Fatal(err)
+		}
+		pdf, err := OpenSplitPDF(coverPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts, err := pdf.Outline()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := pageRanges(parts); got != "1-2,3-4,5-6," || parts[0].Title != "with-cover" {
+			t.Errorf("expected: 1-2,3-4,5-6 with the leading pages named with-cover, got: %+v", parts)
+		}
+	})
+}
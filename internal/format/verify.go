@@ -0,0 +1,290 @@
+package format
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/maruel/natural"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/radam9/manga-tools/internal"
+	"github.com/radam9/manga-tools/internal/archive"
+	"github.com/radam9/manga-tools/internal/images"
+	model2 "github.com/radam9/manga-tools/internal/model"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// VerifyArchive decodes every image of the comic archive, and returns the problems found, none if it's sound.
+func VerifyArchive(archivePath string) []string {
+	var problems []string
+	imagesCount := 0
+	err := walkArchive(archivePath, func(name string, r io.Reader) error {
+		switch {
+		case strings.EqualFold(path.Base(name), comicInfoFileName):
+			var info ComicInfo
+			if err := xml.NewDecoder(r).Decode(&info); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: invalid %s: %v", name, comicInfoFileName, err))
+			}
+		case internal.IsImageFile(name):
+			imagesCount++
+			if err := images.Check(r); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		problems = append(problems, err.Error())
+	} else if imagesCount == 0 {
+		problems = append(problems, "no images")
+	}
+	return problems
+}
+
+// walkArchive calls fn with every file of the archive. A file that can't be read is left to fn to report, as the
+// following files can still be read, but the walk stops at the first entry that can't be reached.
+func walkArchive(archivePath string, fn func(name string, r io.Reader) error) error {
+	r, err := archive.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for {
+		name, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading archive: %w", err)
+		}
+		if err := fn(name, r); err != nil {
+			return err
+		}
+	}
+}
+
+// RepairArchive rebuilds the comic archive from the images that can still be decoded, and keeps the original
+// next to it with a .bak extension, numbered when an older backup is already there. The path of the backup is
+// returned. Rar archives are rebuilt as zip archives, like the saved cbr files.
+func RepairArchive(archivePath string) (string, error) {
+	tempDir, err := os.MkdirTemp("", "manga-tools-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tempDir)
+
+	type salvagedImage struct {
+		name string
+		path model2.FilePath
+	}
+	var salvaged []salvagedImage
+	var info *ComicInfo
+	dropped := 0
+	err = walkArchive(archivePath, func(name string, r io.Reader) error {
+		if strings.EqualFold(path.Base(name), comicInfoFileName) {
+			var comicInfo ComicInfo
+			if err := xml.NewDecoder(r).Decode(&comicInfo); err == nil {
+				info = &comicInfo
+			}
+			return nil
+		}
+		if !internal.IsImageFile(name) {
+			return nil
+		}
+
+		dstPath := filepath.Join(tempDir, fmt.Sprintf("%04d%s", len(salvaged)+dropped, filepath.Ext(name)))
+		if err := extractFile(r, dstPath); err != nil {
+			dropped++
+			return nil
+		}
+		if err := checkImageFile(dstPath); err != nil {
+			dropped++
+			return nil
+		}
+		salvaged = append(salvaged, salvagedImage{name: name, path: dstPath})
+		return nil
+	})
+	if err != nil {
+		// the images read before the archive broke are salvaged.
+		slog.Warn("salvaging the images read before the error", "path", archivePath, "error", err)
+	}
+	if len(salvaged) == 0 {
+		return "", errors.New("no images can be salvaged")
+	}
+
+	slices.SortStableFunc(salvaged, func(a, b salvagedImage) int {
+		if natural.Less(a.name, b.name) {
+			return -1
+		} else if natural.Less(b.name, a.name) {
+			return 1
+		}
+		return 0
+	})
+	var pages []model2.FilePath
+	for _, image := range salvaged {
+		pages = append(pages, image.path)
+	}
+
+	if info == nil {
+		info = &ComicInfo{}
+	}
+	if dropped > 0 {
+		// the page entries don't match the pages anymore.
+		info.Pages = nil
+	}
+	name := strings.TrimSuffix(filepath.Base(archivePath), filepath.Ext(archivePath))
+	book := info.Book(name, pages)
+
+	var saver Format = CBZ{}
+	switch strings.ToLower(filepath.Ext(archivePath)) {
+	case ".cbr":
+		saver = CBR{}
+	case ".cb7":
+		saver = CB7{}
+	case ".cbt":
+		saver = CBT{}
+	}
+	repairedPath := archivePath + ".repaired"
+	if err := saver.Save(repairedPath, book); err != nil {
+		os.Remove(repairedPath)
+		return "", fmt.Errorf("rebuilding archive: %w", err)
+	}
+	backupPath, err := freeBackupPath(archivePath)
+	if err != nil {
+		os.Remove(repairedPath)
+		return "", err
+	}
+	if err := os.Rename(archivePath, backupPath); err != nil {
+		os.Remove(repairedPath)
+		return "", fmt.Errorf("keeping the original archive: %w", err)
+	}
+	if err := os.Rename(repairedPath, archivePath); err != nil {
+		// put the original back rather than leaving nothing at its path.
+		if restoreErr := os.Rename(backupPath, archivePath); restoreErr != nil {
+			return "", fmt.Errorf("moving the repaired archive %q into place: %w, the original is kept as %q", repairedPath, err, backupPath)
+		}
+		os.Remove(repairedPath)
+		return "", fmt.Errorf("moving the repaired archive into place: %w", err)
+	}
+	return backupPath, nil
+}
+
+// freeBackupPath returns the first of name.bak, name.1.bak, name.2.bak... that doesn't exist, so older backups
+// aren't overwritten.
+func freeBackupPath(archivePath string) (string, error) {
+	backupPath := archivePath + ".bak"
+	for i := 1; ; i++ {
+		_, err := os.Lstat(backupPath)
+		if os.IsNotExist(err) {
+			return backupPath, nil
+		} else if err != nil {
+			return "", fmt.Errorf("checking backup %q: %w", backupPath, err)
+		}
+		backupPath = fmt.Sprintf("%s.%d.bak", archivePath, i)
+	}
+}
+
+func checkImageFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return images.Check(f)
+}
+
+// VerifyPDF validates the pdf, and decodes the images of its pages. The images pdfcpu can't extract, e.g. jpeg 2000
+// images, are skipped as they can't be checked.
+func VerifyPDF(pdfPath string) []string {
+	f, err := os.Open(pdfPath)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	defer f.Close()
+
+	conf := model.NewDefaultConfiguration()
+	conf.Cmd = model.EXTRACTIMAGES
+	ctx, err := api.ReadValidateAndOptimize(f, conf)
+	if err != nil {
+		return []string{fmt.Sprintf("invalid pdf: %v", err)}
+	}
+
+	var problems []string
+	for pageNr := 1; pageNr <= ctx.PageCount; pageNr++ {
+		for _, objNr := range pdfPageImages(ctx, pageNr) {
+			imageObject := ctx.Optimize.ImageObjects[objNr]
+			img, err := pdfcpu.ExtractImage(ctx, imageObject.ImageDict, false, imageObject.ResourceNames[pageNr-1], objNr, false)
+			if err != nil {
+				slog.Warn("skipping unsupported pdf image", "path", pdfPath, "page", pageNr, "object", objNr, "error", err)
+				continue
+			}
+			if img == nil {
+				continue
+			}
+			if err := images.Check(img); err != nil {
+				problems = append(problems, fmt.Sprintf("page %d: image %d: %v", pageNr, objNr, err))
+			}
+		}
+	}
+	return problems
+}
+
+var pageNumberPattern = regexp.MustCompile(`\d+`)
+
+// VerifyImageDir decodes the images of a directory of pages, and looks for gaps in their numbering.
+// The page number is the last number in the file name.
+func VerifyImageDir(paths []model2.FilePath) []string {
+	var problems []string
+	var numbers []int
+	for _, imagePath := range paths {
+		if err := checkImageFile(imagePath); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", filepath.Base(imagePath), err))
+		}
+
+		name := strings.TrimSuffix(filepath.Base(imagePath), filepath.Ext(imagePath))
+		matches := pageNumberPattern.FindAllString(name, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		if number, err := strconv.Atoi(matches[len(matches)-1]); err == nil {
+			numbers = append(numbers, number)
+		}
+	}
+	return append(problems, numberingProblems(numbers)...)
+}
+
+// numberingProblems reports the missing and the duplicated page numbers, the pages can start at 0 or 1.
+func numberingProblems(numbers []int) []string {
+	if len(numbers) == 0 {
+		return nil
+	}
+	slices.Sort(numbers)
+
+	var problems []string
+	expected := min(numbers[0], 1)
+	for i, number := range numbers {
+		if i > 0 && number == numbers[i-1] {
+			problems = append(problems, fmt.Sprintf("duplicated page %d", number))
+			continue
+		}
+		if number > expected {
+			if number-1 == expected {
+				problems = append(problems, fmt.Sprintf("missing page %d", expected))
+			} else {
+				problems = append(problems, fmt.Sprintf("missing pages %d-%d", expected, number-1))
+			}
+		}
+		expected = number + 1
+	}
+	return problems
+}
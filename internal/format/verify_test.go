@@ -0,0 +1,107 @@
+package format
+
+import (
+	"archive/zip"
+	"bytes"
+	"github.com/radam9/manga-tools/internal/testutil"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNumberingProblems(t *testing.T) {
+	tests := []struct {
+		name     string
+		numbers  []int
+		expected []string
+	}{
+		{name: "from one", numbers: []int{3, 1, 2}},
+		{name: "from zero", numbers: []int{0, 1, 2}},
+		{name: "missing first pages", numbers: []int{3, 4}, expected: []string{"missing pages 1-2"}},
+		{name: "missing page", numbers: []int{1, 2, 4}, expected: []string{"missing page 3"}},
+		{name: "duplicated page", numbers: []int{1, 2, 2, 3}, expected: []string{"duplicated page 2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := numberingProblems(tt.numbers)
+			if !slices.Equal(result, tt.expected) {
+				t.Errorf("expected: %q, got: %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestVerifyAndRepairArchive(t *testing.T) {
+	dir := t.TempDir()
+	imagePath := filepath.Join(dir, "page.png")
+	testutil.WritePNG(t, imagePath, 10, 20)
+	image, err := os.ReadFile(imagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archivePath := filepath.Join(dir, "broken.cbz")
+	f, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	for name, content := range map[string][]byte{"001.png": image, "002.png": image[:len(image)/2], "003.png": image} {
+		entry, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entry.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	problems := VerifyArchive(archivePath)
+	if len(problems) != 1 || !strings.HasPrefix(problems[0], "002.png") {
+		t.Errorf("expected: a problem with 002.png, got: %q", problems)
+	}
+
+	original, err := os.ReadFile(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backupPath, err := RepairArchive(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems := VerifyArchive(archivePath); len(problems) != 0 {
+		t.Errorf("expected: a sound repaired archive, got: %q", problems)
+	}
+	if expected := archivePath + ".bak"; backupPath != expected {
+		t.Errorf("expected: %s, got: %s", expected, backupPath)
+	}
+
+	// a second repair numbers its backup instead of overwriting the first one.
+	backupPath, err = RepairArchive(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := archivePath + ".1.bak"; backupPath != expected {
+		t.Errorf("expected: %s, got: %s", expected, backupPath)
+	}
+	backup, err := os.ReadFile(archivePath + ".bak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(backup, original) {
+		t.Errorf("expected: the original archive kept as %s.bak", archivePath)
+	}
+	book, err := ReadArchive(t.TempDir(), archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.PagesCount() != 2 {
+		t.Errorf("expected: %d, got: %d", 2, book.PagesCount())
+	}
+}
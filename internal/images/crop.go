@@ -0,0 +1,114 @@
+package images
+
+import (
+	"golang.org/x/image/draw"
+	"image"
+	"math"
+	"slices"
+)
+
+const (
+	// DefaultCropTolerance is the luminance difference to the border colour that still counts as border.
+	DefaultCropTolerance = 24
+	// cropNoiseRatio is the ratio of the pixels of a line that can differ from the border colour, for the dust and
+	// the jpeg artifacts of the scans. Page numbers are bigger than that, so the cropping stops at them.
+	cropNoiseRatio = 0.005
+	// cropPaddingRatio is the part of the dimension left around the content, so nothing ends up on the edge.
+	cropPaddingRatio = 0.01
+	// cropLimitRatio caps how much of the dimension can be removed from each side.
+	cropLimitRatio = 0.15
+)
+
+// cropMargins trims the uniform borders of the image. Every side is cropped up to the first line that differs
+// from the colour of its outermost line, and no side loses more than cropLimitRatio of the dimension.
+func cropMargins(img image.Image, tolerance int) image.Image {
+	bounds := img.Bounds()
+	gray := image.NewGray(bounds)
+	draw.Draw(gray, bounds, img, bounds.Min, draw.Src)
+
+	row := func(y int) []uint8 {
+		start := gray.PixOffset(bounds.Min.X, y)
+		return gray.Pix[start : start+bounds.Dx()]
+	}
+	column := func(x int) []uint8 {
+		result := make([]uint8, 0, bounds.Dy())
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			result = append(result, gray.Pix[gray.PixOffset(x, y)])
+		}
+		return result
+	}
+
+	// a blank page has nothing to crop around, it's kept as it is.
+	if isUniform(gray.Pix, median(row(bounds.Min.Y)), tolerance) {
+		return img
+	}
+
+	top := marginSize(bounds.Dy(), tolerance, func(i int) []uint8 { return row(bounds.Min.Y + i) })
+	bottom := marginSize(bounds.Dy(), tolerance, func(i int) []uint8 { return row(bounds.Max.Y - 1 - i) })
+	left := marginSize(bounds.Dx(), tolerance, func(i int) []uint8 { return column(bounds.Min.X + i) })
+	right := marginSize(bounds.Dx(), tolerance, func(i int) []uint8 { return column(bounds.Max.X - 1 - i) })
+
+	vertical := padding(bounds.Dy())
+	horizontal := padding(bounds.Dx())
+	rect := image.Rect(
+		bounds.Min.X+max(0, left-horizontal),
+		bounds.Min.Y+max(0, top-vertical),
+		bounds.Max.X-max(0, right-horizontal),
+		bounds.Max.Y-max(0, bottom-vertical),
+	)
+	if rect.Eq(bounds) || rect.Empty() {
+		return img
+	}
+	return crop(img, rect)
+}
+
+func limit(size int) int {
+	return int(float64(size) * cropLimitRatio)
+}
+
+func padding(size int) int {
+	return int(math.Ceil(float64(size) * cropPaddingRatio))
+}
+
+// marginSize returns how many lines of one side have the colour of the border, up to the limit.
+// line(i) is the i-th line from the edge.
+func marginSize(size, tolerance int, line func(i int) []uint8) int {
+	maximum := limit(size)
+	if maximum == 0 {
+		return 0
+	}
+
+	border := median(line(0))
+	margin := 0
+	for margin < maximum && isUniform(line(margin), border, tolerance) {
+		margin++
+	}
+	return margin
+}
+
+func isUniform(line []uint8, border uint8, tolerance int) bool {
+	allowed := int(float64(len(line)) * cropNoiseRatio)
+	different := 0
+	for _, v := range line {
+		if abs(int(v)-int(border)) > tolerance {
+			different++
+			if different > allowed {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func median(line []uint8) uint8 {
+	sorted := slices.Clone(line)
+	slices.Sort(sorted)
+	return sorted[len(sorted)/2]
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,46 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestCropMargins(t *testing.T) {
+	page := func(content image.Rectangle, extra ...image.Point) image.Image {
+		img := image.NewGray(image.Rect(0, 0, 200, 300))
+		for i := range img.Pix {
+			img.Pix[i] = 255
+		}
+		for y := content.Min.Y; y < content.Max.Y; y++ {
+			for x := content.Min.X; x < content.Max.X; x++ {
+				img.SetGray(x, y, color.Gray{Y: 0})
+			}
+		}
+		for _, p := range extra {
+			img.SetGray(p.X, p.Y, color.Gray{Y: 0})
+		}
+		return img
+	}
+
+	tests := []struct {
+		name     string
+		img      image.Image
+		expected image.Rectangle
+	}{
+		// 2px and 3px of padding are left around the content.
+		{name: "uniform borders", img: page(image.Rect(20, 30, 180, 270)), expected: image.Rect(0, 0, 164, 246)},
+		{name: "borders wider than the limit", img: page(image.Rect(50, 100, 150, 200)), expected: image.Rect(0, 0, 200-2*28, 300-2*42)},
+		{name: "dust is ignored", img: page(image.Rect(20, 30, 180, 270), image.Pt(5, 5)), expected: image.Rect(0, 0, 164, 246)},
+		{name: "page number stops the crop", img: page(image.Rect(20, 30, 180, 270), image.Pt(99, 290), image.Pt(100, 290)), expected: image.Rect(0, 0, 164, 300-27-6)},
+		{name: "blank page", img: page(image.Rectangle{}), expected: image.Rect(0, 0, 200, 300)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cropMargins(tt.img, DefaultCropTolerance).Bounds()
+			if got.Size() != tt.expected.Size() {
+				t.Errorf("expected: %v, got: %v", tt.expected.Size(), got.Size())
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/HugoSmits86/nativewebp"
+	_ "github.com/gen2brain/avif"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Type is the format of an image, detected from its content rather than its extension.
+type Type string
+
+const (
+	JPEG Type = "jpeg"
+	PNG  Type = "png"
+	GIF  Type = "gif"
+	WebP Type = "webp"
+	TIFF Type = "tiff"
+	AVIF Type = "avif"
+)
+
+var ErrUnknownType = errors.New("unknown image type")
+
+// OutputTypes are the types images can be transcoded to.
+var OutputTypes = []Type{JPEG, PNG, WebP}
+
+func ParseType(s string) (Type, error) {
+	s = strings.ToLower(strings.TrimPrefix(s, "."))
+	if s == "jpg" {
+		s = string(JPEG)
+	}
+	for _, t := range OutputTypes {
+		if s == string(t) {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported image format %q, must be one of %v", s, OutputTypes)
+}
+
+func (t Type) Extension() string {
+	if t == JPEG {
+		return ".jpg"
+	}
+	return "." + string(t)
+}
+
+func (t Type) MediaType() string {
+	return "image/" + string(t)
+}
+
+// Detect returns the type of the image from its magic bytes.
+func Detect(path string) (Type, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, 12)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", fmt.Errorf("reading image %q: %w", path, err)
+	}
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, []byte("\xff\xd8\xff")):
+		return JPEG, nil
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return PNG, nil
+	case bytes.HasPrefix(head, []byte("GIF8")):
+		return GIF, nil
+	case bytes.HasPrefix(head, []byte("II*\x00")), bytes.HasPrefix(head, []byte("MM\x00*")):
+		return TIFF, nil
+	case len(head) == 12 && bytes.HasPrefix(head, []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WEBP")):
+		return WebP, nil
+	case len(head) == 12 && bytes.Equal(head[4:8], []byte("ftyp")) && (bytes.Equal(head[8:12], []byte("avif")) || bytes.Equal(head[8:12], []byte("avis"))):
+		return AVIF, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownType, path)
+}
+
+// Stats returns the size in bytes and the dimensions of an image, the values are zero if they can't be read.
+func Stats(path string) (int64, int, int) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, 0
+	}
+	defer f.Close()
+
+	var size int64
+	if stat, err := f.Stat(); err == nil {
+		size = stat.Size()
+	}
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return size, 0, 0
+	}
+	return size, config.Width, config.Height
+}
+
+// Transcode decodes the image (the first frame of animated images) and encodes it as a png, jpeg or webp file in dstDir.
+func Transcode(path, dstDir string, to Type) (string, error) {
+	img, err := decode(path)
+	if err != nil {
+		return "", err
+	}
+	return encode(img, path, dstDir, to, 0)
+}
+
+func decode(path string) (image.Image, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	img, _, err := image.Decode(src)
+	if err != nil {
+		return nil, fmt.Errorf("decoding image %q: %w", path, err)
+	}
+	return img, nil
+}
+
+// Check decodes the image to make sure it isn't broken.
+func Check(r io.Reader) error {
+	if _, _, err := image.Decode(r); err != nil {
+		return fmt.Errorf("decoding image: %w", err)
+	}
+	return nil
+}
+
+// Thumbnail decodes the image, downscales it to fit in the box, and writes it to w as a jpeg.
+func Thumbnail(w io.Writer, r io.Reader, width, height int) error {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return fmt.Errorf("decoding image: %w", err)
+	}
+	img = Pipeline{Width: width, Height: height}.resize(img)
+	return encodeTo(w, img, JPEG, 80)
+}
+
+// encode writes the image as a new file of the given type into dstDir, named after the source image.
+func encode(img image.Image, srcPath, dstDir string, to Type, quality int) (string, error) {
+	name := strings.TrimSuffix(filepath.Base(srcPath), filepath.Ext(srcPath))
+	dst, err := os.CreateTemp(dstDir, name+"-*"+to.Extension())
+	if err != nil {
+		return "", fmt.Errorf("creating image: %w", err)
+	}
+	defer dst.Close()
+
+	if err := encodeTo(dst, img, to, quality); err != nil {
+		return "", fmt.Errorf("encoding image %q: %w", srcPath, err)
+	}
+	return dst.Name(), dst.Close()
+}
+
+// encodeTo encodes the image, the quality is only used by jpeg and defaults to 90.
+func encodeTo(w io.Writer, img image.Image, to Type, quality int) error {
+	switch to {
+	case PNG:
+		return png.Encode(w, img)
+	case JPEG:
+		if quality <= 0 {
+			quality = 90
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case WebP:
+		return nativewebp.Encode(w, img, nil)
+	}
+	return fmt.Errorf("encoding %s images is not supported", to)
+}
+
+// Convert transcodes the image to the given type, unless it is already of that type.
+func Convert(path, dstDir string, to Type) (string, error) {
+	imageType, err := Detect(path)
+	if err != nil {
+		return "", err
+	}
+	if imageType == to {
+		return path, nil
+	}
+	return Transcode(path, dstDir, to)
+}
+
+// EnsureTypes returns the paths of the images with every image that isn't of the allowed types transcoded to png.
+func EnsureTypes(paths []string, dstDir string, allowed ...Type) ([]string, error) {
+	result := make([]string, 0, len(paths))
+	for _, path := range paths {
+		imageType, err := Detect(path)
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(allowed, imageType) {
+			result = append(result, path)
+			continue
+		}
+
+		transcoded, err := Transcode(path, dstDir, PNG)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, transcoded)
+	}
+	return result, nil
+}
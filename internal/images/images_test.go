@@ -0,0 +1,164 @@
+package images
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected Type
+	}{
+		{name: "jpeg", data: "\xff\xd8\xff\xe0\x00\x10JFIF\x00", expected: JPEG},
+		{name: "png", data: "\x89PNG\r\n\x1a\n\x00\x00\x00\x0d", expected: PNG},
+		{name: "gif", data: "GIF89a\x01\x00\x01\x00", expected: GIF},
+		{name: "webp", data: "RIFF\x24\x00\x00\x00WEBPVP8 ", expected: WebP},
+		{name: "tiff little endian", data: "II*\x00\x08\x00\x00\x00", expected: TIFF},
+		{name: "tiff big endian", data: "MM\x00*\x00\x00\x00\x08", expected: TIFF},
+		{name: "avif", data: "\x00\x00\x00\x1cftypavif\x00\x00", expected: AVIF},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the extension is wrong on purpose, the type is detected from the content.
+			path := filepath.Join(dir, tt.name+".jpg")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Detect(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected: %s, got: %s", tt.expected, got)
+			}
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		path := filepath.Join(dir, "unknown.png")
+		if err := os.WriteFile(path, []byte("%PDF-1.7"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Detect(path); !errors.Is(err, ErrUnknownType) {
+			t.Errorf("expected: %v, got: %v", ErrUnknownType, err)
+		}
+	})
+}
+
+func TestEnsureTypes(t *testing.T) {
+	dir := t.TempDir()
+	img := image.NewPaletted(image.Rect(0, 0, 4, 6), color.Palette{color.Black, color.White})
+
+	gifPath := filepath.Join(dir, "page.gif")
+	gifFile, err := os.Create(gifPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gif.Encode(gifFile, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	gifFile.Close()
+
+	pngPath := filepath.Join(dir, "page.png")
+	pngFile, err := os.Create(pngPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(pngFile, img); err != nil {
+		t.Fatal(err)
+	}
+	pngFile.Close()
+
+	got, err := EnsureTypes([]string{gifPath, pngPath}, dir, JPEG, PNG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] == gifPath || got[1] != pngPath {
+		t.Fatalf("expected the gif to be transcoded and the png to be kept, got: %v", got)
+	}
+	if imageType, err := Detect(got[0]); err != nil || imageType != PNG {
+		t.Errorf("expected: %s, got: %s (%v)", PNG, imageType, err)
+	}
+	if _, width, height := Stats(got[0]); width != 4 || height != 6 {
+		t.Errorf("expected: 4x6, got: %dx%d", width, height)
+	}
+}
+
+func TestParseType(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected Type
+		err      bool
+	}{
+		{s: "jpg", expected: JPEG},
+		{s: ".JPEG", expected: JPEG},
+		{s: "png", expected: PNG},
+		{s: "webp", expected: WebP},
+		{s: "gif", err: true},
+		{s: "avif", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseType(tt.s)
+			if (err != nil) != tt.err {
+				t.Fatalf("expected error: %t, got: %v", tt.err, err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected: %s, got: %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "page.png")
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, image.NewGray(image.Rect(0, 0, 4, 6))); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	tests := []struct {
+		to        Type
+		extension string
+		kept      bool
+	}{
+		{to: PNG, extension: ".png", kept: true},
+		{to: JPEG, extension: ".jpg"},
+		{to: WebP, extension: ".webp"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.to), func(t *testing.T) {
+			got, err := Convert(src, t.TempDir(), tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got == src) != tt.kept {
+				t.Errorf("expected kept: %t, got: %s", tt.kept, got)
+			}
+			if filepath.Ext(got) != tt.extension {
+				t.Errorf("expected: %s, got: %s", tt.extension, filepath.Ext(got))
+			}
+			if imageType, err := Detect(got); err != nil || imageType != tt.to {
+				t.Errorf("expected: %s, got: %s (%v)", tt.to, imageType, err)
+			}
+			if _, width, height := Stats(got); width != 4 || height != 6 {
+				t.Errorf("expected: 4x6, got: %dx%d", width, height)
+			}
+		})
+	}
+}
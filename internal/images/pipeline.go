@@ -0,0 +1,341 @@
+package images
+
+import (
+	"fmt"
+	"golang.org/x/image/draw"
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Pipeline processes the pages before they are saved, to fit the screen of the device they are read on.
+type Pipeline struct {
+	// Width and Height are the box the pages are downscaled to fit in, zero disables resizing.
+	Width  int
+	Height int
+	// Grayscale converts the pages to grayscale, colour pages are kept as they are if SkipColorPages is set.
+	Grayscale      bool
+	SkipColorPages bool
+	// Gamma is applied as `out = in^Gamma`, values above 1 darken the mid-tones which helps on e-ink screens.
+	// Zero and 1 disable it.
+	Gamma float64
+	// Contrast stretches (positive) or flattens (negative) the tones around the mid-grey, from -1 to 1.
+	Contrast float64
+	// Quality re-encodes the pages as jpeg with the given quality (1-100) unless another Format is set.
+	Quality int
+	// Format is the image type the pages are encoded to, empty keeps their type.
+	Format Type
+	// Spreads is what happens to the double-page spreads, the pages wider than tall.
+	Spreads Spreads
+	// RightToLeft splits the spreads right half first, and rotates them with their right edge on top.
+	RightToLeft bool
+	// CropMargins trims the uniform white or black borders of the pages, CropTolerance is how far (0-255) the
+	// luminance of a border pixel can be from the colour of the border.
+	CropMargins   bool
+	CropTolerance int
+}
+
+// Spreads is how the double-page spreads are processed.
+type Spreads string
+
+const (
+	// SpreadsKeep keeps the spreads as they are, they are marked as double pages.
+	SpreadsKeep Spreads = "keep"
+	// SpreadsSplit splits the spreads into two pages, in reading order.
+	SpreadsSplit Spreads = "split"
+	// SpreadsRotate rotates the spreads by 90 degrees to fill portrait screens.
+	SpreadsRotate Spreads = "rotate"
+)
+
+var SpreadModes = []Spreads{SpreadsKeep, SpreadsSplit, SpreadsRotate}
+
+func ParseSpreads(s string) (Spreads, error) {
+	for _, mode := range SpreadModes {
+		if strings.EqualFold(s, string(mode)) {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("unknown spreads mode %q, must be one of %v", s, SpreadModes)
+}
+
+// Page is an image written by the pipeline.
+type Page struct {
+	Path string
+	// DoublePage is set for the spreads that are kept as one page.
+	DoublePage bool
+}
+
+// Profile is a named pipeline for a device.
+type Profile struct {
+	Name        string
+	Description string
+	Pipeline    Pipeline
+}
+
+var Profiles = []Profile{
+	{
+		Name:        "kindle-paperwhite",
+		Description: "Kindle Paperwhite (11th gen), 1236x1648 grayscale e-ink",
+		Pipeline:    Pipeline{Width: 1236, Height: 1648, Grayscale: true, Gamma: 1.8, Quality: 85, Format: JPEG},
+	},
+	{
+		Name:        "kindle-oasis",
+		Description: "Kindle Oasis, 1264x1680 grayscale e-ink",
+		Pipeline:    Pipeline{Width: 1264, Height: 1680, Grayscale: true, Gamma: 1.8, Quality: 85, Format: JPEG},
+	},
+	{
+		Name:        "kobo-clara",
+		Description: "Kobo Clara HD/2E, 1072x1448 grayscale e-ink",
+		Pipeline:    Pipeline{Width: 1072, Height: 1448, Grayscale: true, Gamma: 1.8, Quality: 85, Format: JPEG},
+	},
+	{
+		Name:        "kobo-libra",
+		Description: "Kobo Libra 2, 1264x1680 grayscale e-ink",
+		Pipeline:    Pipeline{Width: 1264, Height: 1680, Grayscale: true, Gamma: 1.8, Quality: 85, Format: JPEG},
+	},
+	{
+		Name:        "tablet-1080p",
+		Description: "1080x1920 colour tablet",
+		Pipeline:    Pipeline{Width: 1080, Height: 1920, Quality: 90, Format: JPEG},
+	},
+}
+
+func FindProfile(name string) (Profile, error) {
+	var names []string
+	for _, profile := range Profiles {
+		if strings.EqualFold(profile.Name, name) {
+			return profile, nil
+		}
+		names = append(names, profile.Name)
+	}
+	return Profile{}, fmt.Errorf("unknown profile %q, must be one of %v", name, names)
+}
+
+// Enabled returns false if the pipeline leaves every image untouched.
+func (p Pipeline) Enabled() bool {
+	return p.Format != "" || p.decodes(true)
+}
+
+// decodes returns true if the images have to be decoded, rather than only transcoded. Splitting or rotating the
+// spreads only decodes the spreads, the other pages are left as they are.
+func (p Pipeline) decodes(spread bool) bool {
+	return p.resizes() || p.Grayscale || p.adjustsTones() || p.Quality > 0 || (spread && p.transformsSpreads()) || p.CropMargins
+}
+
+func (p Pipeline) transformsSpreads() bool {
+	return p.Spreads == SpreadsSplit || p.Spreads == SpreadsRotate
+}
+
+func (p Pipeline) resizes() bool {
+	return p.Width > 0 && p.Height > 0
+}
+
+func (p Pipeline) adjustsTones() bool {
+	return (p.Gamma > 0 && p.Gamma != 1) || p.Contrast != 0
+}
+
+// Process writes the processed image into dstDir and returns the pages it became, the spreads that are split
+// become two pages. The image is returned untouched if the pipeline has nothing to do.
+func (p Pipeline) Process(path, dstDir string) ([]Page, error) {
+	if !p.Enabled() {
+		_, width, height := Stats(path)
+		return []Page{{Path: path, DoublePage: width > height}}, nil
+	}
+
+	imageType, err := Detect(path)
+	if err != nil {
+		return nil, err
+	}
+	// the size of the images that can't be read without decoding them is unknown, they're decoded in case they're spreads.
+	_, width, height := Stats(path)
+	if !p.decodes(width > height || width == 0) {
+		if p.Format != "" {
+			if path, err = Convert(path, dstDir, p.Format); err != nil {
+				return nil, err
+			}
+		}
+		return []Page{{Path: path, DoublePage: width > height}}, nil
+	}
+
+	img, err := decode(path)
+	if err != nil {
+		return nil, err
+	}
+
+	outputType := p.Format
+	if outputType == "" {
+		outputType = imageType
+		if p.Quality > 0 {
+			outputType = JPEG
+		}
+	}
+	if !slices.Contains(OutputTypes, outputType) {
+		outputType = PNG
+	}
+
+	if p.CropMargins {
+		img = cropMargins(img, p.CropTolerance)
+	}
+
+	doublePage := isSpread(img)
+	parts := []image.Image{img}
+	if doublePage {
+		switch p.Spreads {
+		case SpreadsSplit:
+			parts = splitSpread(img, p.RightToLeft)
+			doublePage = false
+		case SpreadsRotate:
+			parts = []image.Image{rotate(img, p.RightToLeft)}
+			doublePage = false
+		}
+	}
+
+	result := make([]Page, 0, len(parts))
+	for _, part := range parts {
+		part = p.resize(part)
+		if p.Grayscale && !(p.SkipColorPages && isColor(part)) {
+			part = toGray(part)
+		}
+		if p.adjustsTones() {
+			part = adjustTones(part, p.toneCurve())
+		}
+		partPath, err := encode(part, path, dstDir, outputType, p.Quality)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, Page{Path: partPath, DoublePage: doublePage})
+	}
+	return result, nil
+}
+
+func isSpread(img image.Image) bool {
+	return img.Bounds().Dx() > img.Bounds().Dy()
+}
+
+// splitSpread returns the two halves of the spread in reading order.
+func splitSpread(img image.Image, rightToLeft bool) []image.Image {
+	bounds := img.Bounds()
+	middle := bounds.Min.X + bounds.Dx()/2
+	left := crop(img, image.Rect(bounds.Min.X, bounds.Min.Y, middle, bounds.Max.Y))
+	right := crop(img, image.Rect(middle, bounds.Min.Y, bounds.Max.X, bounds.Max.Y))
+	if rightToLeft {
+		return []image.Image{right, left}
+	}
+	return []image.Image{left, right}
+}
+
+func crop(img image.Image, rect image.Rectangle) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, rect.Min, draw.Src)
+	return dst
+}
+
+// rotate turns the image by 90 degrees so the half that is read first is on top, clockwise for left to right
+// and counterclockwise for right to left.
+func rotate(img image.Image, rightToLeft bool) image.Image {
+	bounds := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dy(), bounds.Dx()))
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			dx, dy := x-bounds.Min.X, y-bounds.Min.Y
+			if rightToLeft {
+				dst.Set(dy, bounds.Dx()-1-dx, img.At(x, y))
+			} else {
+				dst.Set(bounds.Dy()-1-dy, dx, img.At(x, y))
+			}
+		}
+	}
+	return dst
+}
+
+// resize downscales the image to fit in the pipeline box, keeping its aspect ratio. Images are never upscaled.
+func (p Pipeline) resize(img image.Image) image.Image {
+	if !p.resizes() {
+		return img
+	}
+	bounds := img.Bounds()
+	scale := math.Min(float64(p.Width)/float64(bounds.Dx()), float64(p.Height)/float64(bounds.Dy()))
+	if scale >= 1 {
+		return img
+	}
+
+	width := max(1, int(math.Round(float64(bounds.Dx())*scale)))
+	height := max(1, int(math.Round(float64(bounds.Dy())*scale)))
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// toneCurve returns the lookup table of the gamma and contrast adjustments of 8 bit channels.
+func (p Pipeline) toneCurve() [256]uint8 {
+	var curve [256]uint8
+	for i := range curve {
+		v := float64(i) / 255
+		if p.Gamma > 0 {
+			v = math.Pow(v, p.Gamma)
+		}
+		v = (v-0.5)*(1+p.Contrast) + 0.5
+		curve[i] = uint8(math.Round(math.Max(0, math.Min(1, v)) * 255))
+	}
+	return curve
+}
+
+func adjustTones(img image.Image, curve [256]uint8) image.Image {
+	bounds := img.Bounds()
+	if gray, ok := img.(*image.Gray); ok {
+		dst := image.NewGray(bounds)
+		for i, v := range gray.Pix {
+			dst.Pix[i] = curve[v]
+		}
+		return dst
+	}
+
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, img, bounds.Min, draw.Src)
+	for i := 0; i < len(dst.Pix); i += 4 {
+		dst.Pix[i] = curve[dst.Pix[i]]
+		dst.Pix[i+1] = curve[dst.Pix[i+1]]
+		dst.Pix[i+2] = curve[dst.Pix[i+2]]
+	}
+	return dst
+}
+
+func toGray(img image.Image) image.Image {
+	if _, ok := img.(*image.Gray); ok {
+		return img
+	}
+	bounds := img.Bounds()
+	dst := image.NewGray(bounds)
+	draw.Draw(dst, bounds, img, bounds.Min, draw.Src)
+	return dst
+}
+
+// isColor samples the pixels of the image, and reports whether enough of them are saturated to be a colour page.
+// Scans of black and white pages are slightly tinted, so the pixels have to be clearly saturated to count.
+func isColor(img image.Image) bool {
+	switch img.(type) {
+	case *image.Gray, *image.Gray16:
+		return false
+	}
+
+	const saturationThreshold = 32
+	const colorRatio = 0.02
+
+	bounds := img.Bounds()
+	step := max(1, int(math.Sqrt(float64(bounds.Dx()*bounds.Dy())/10000)))
+	samples, saturated := 0, 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			high := max(c.R, c.G, c.B)
+			low := min(c.R, c.G, c.B)
+			if int(high)-int(low) > saturationThreshold {
+				saturated++
+			}
+			samples++
+		}
+	}
+	return samples > 0 && float64(saturated)/float64(samples) > colorRatio
+}
@@ -0,0 +1,156 @@
+package images
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestImage(t *testing.T, path string, width, height int, c color.Color) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, c)
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPipelineProcess(t *testing.T) {
+	white := color.White
+	red := color.RGBA{R: 200, A: 255}
+	tests := []struct {
+		name       string
+		pipeline   Pipeline
+		color      color.Color
+		srcWidth   int
+		pages      int
+		width      int
+		height     int
+		gray       bool
+		doublePage bool
+		typ        Type
+	}{
+		{name: "resize keeps aspect ratio", pipeline: Pipeline{Width: 100, Height: 100}, color: white, srcWidth: 200, pages: 1, width: 50, height: 100, typ: PNG},
+		{name: "grayscale", pipeline: Pipeline{Grayscale: true}, color: red, srcWidth: 200, pages: 1, width: 200, height: 400, gray: true, typ: PNG},
+		{name: "grayscale skips colour pages", pipeline: Pipeline{Grayscale: true, SkipColorPages: true}, color: red, srcWidth: 200, pages: 1, width: 200, height: 400, typ: PNG},
+		{name: "quality encodes jpeg", pipeline: Pipeline{Quality: 80}, color: white, srcWidth: 200, pages: 1, width: 200, height: 400, typ: JPEG},
+		{name: "profile", pipeline: Profiles[0].Pipeline, color: white, srcWidth: 200, pages: 1, width: 200, height: 400, gray: true, typ: JPEG},
+		{name: "spread is kept", pipeline: Pipeline{}, color: white, srcWidth: 800, pages: 1, width: 800, height: 400, doublePage: true, typ: PNG},
+		{name: "spread is split", pipeline: Pipeline{Spreads: SpreadsSplit}, color: white, srcWidth: 800, pages: 2, width: 400, height: 400, typ: PNG},
+		{name: "spread is rotated", pipeline: Pipeline{Spreads: SpreadsRotate}, color: white, srcWidth: 800, pages: 1, width: 400, height: 800, typ: PNG},
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "page.png")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeTestImage(t, src, tt.srcWidth, 400, tt.color)
+			pages, err := tt.pipeline.Process(src, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pages) != tt.pages {
+				t.Fatalf("expected: %d pages, got: %d", tt.pages, len(pages))
+			}
+			for _, page := range pages {
+				if page.DoublePage != tt.doublePage {
+					t.Errorf("expected double page: %t, got: %t", tt.doublePage, page.DoublePage)
+				}
+				if typ, _ := Detect(page.Path); typ != tt.typ {
+					t.Errorf("expected: %s, got: %s", tt.typ, typ)
+				}
+				img, err := decode(page.Path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if img.Bounds().Dx() != tt.width || img.Bounds().Dy() != tt.height {
+					t.Errorf("expected: %dx%d, got: %dx%d", tt.width, tt.height, img.Bounds().Dx(), img.Bounds().Dy())
+				}
+				if _, gray := img.(*image.Gray); gray != tt.gray {
+					t.Errorf("expected gray: %t, got: %t", tt.gray, gray)
+				}
+			}
+		})
+	}
+}
+
+func TestPipelineKeepsPortraitPages(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "page.jpg")
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(f, image.NewGray(image.Rect(0, 0, 200, 400)), &jpeg.Options{Quality: 60}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, spreads := range []Spreads{SpreadsSplit, SpreadsRotate} {
+		t.Run(string(spreads), func(t *testing.T) {
+			pages, err := Pipeline{Spreads: spreads}.Process(src, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pages) != 1 {
+				t.Fatalf("expected: 1 page, got: %d", len(pages))
+			}
+			result, err := os.ReadFile(pages[0].Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(result, data) {
+				t.Errorf("expected: %s to be left as is, got: %s", src, pages[0].Path)
+			}
+		})
+	}
+}
+
+func TestSplitSpreadReadingOrder(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for y := range 2 {
+		img.Set(0, y, color.Black)
+		img.Set(3, y, color.White)
+	}
+
+	tests := []struct {
+		name        string
+		rightToLeft bool
+		first       color.Gray
+	}{
+		{name: "left to right", rightToLeft: false, first: color.Gray{Y: 0}},
+		{name: "right to left", rightToLeft: true, first: color.Gray{Y: 255}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			halves := splitSpread(img, tt.rightToLeft)
+			// the outer edge of the first half is the column that was painted.
+			x := 0
+			if tt.rightToLeft {
+				x = 1
+			}
+			got := color.GrayModel.Convert(halves[0].At(x, 0)).(color.Gray)
+			if got != tt.first {
+				t.Errorf("expected: %v, got: %v", tt.first, got)
+			}
+		})
+	}
+}
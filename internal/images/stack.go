@@ -0,0 +1,56 @@
+package images
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+)
+
+// Stack draws the images one under the other, centered on a white page as wide as the widest image,
+// and writes the result as a png file in dstDir named after the first image.
+func Stack(paths []string, dstDir string) (string, error) {
+	var parts []image.Image
+	for _, path := range paths {
+		img, err := decode(path)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, img)
+	}
+	return encode(stack(parts), paths[0], dstDir, PNG, 0)
+}
+
+// StackTo draws the images one under the other like Stack, and writes the result as a png to w.
+func StackTo(w io.Writer, srcs []io.Reader) error {
+	var parts []image.Image
+	for _, src := range srcs {
+		img, _, err := image.Decode(src)
+		if err != nil {
+			return fmt.Errorf("decoding image: %w", err)
+		}
+		parts = append(parts, img)
+	}
+	return png.Encode(w, stack(parts))
+}
+
+func stack(parts []image.Image) image.Image {
+	var width, height int
+	for _, part := range parts {
+		width = max(width, part.Bounds().Dx())
+		height += part.Bounds().Dy()
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	y := 0
+	for _, part := range parts {
+		bounds := part.Bounds()
+		x := (width - bounds.Dx()) / 2
+		draw.Draw(dst, image.Rect(x, y, x+bounds.Dx(), y+bounds.Dy()), part, bounds.Min, draw.Over)
+		y += bounds.Dy()
+	}
+	return dst
+}
@@ -0,0 +1,34 @@
+package images
+
+import (
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+func TestStack(t *testing.T) {
+	dir := t.TempDir()
+	top := filepath.Join(dir, "top.png")
+	writeTestImage(t, top, 100, 40, color.Black)
+	bottom := filepath.Join(dir, "bottom.png")
+	writeTestImage(t, bottom, 60, 50, color.Black)
+
+	path, err := Stack([]string{top, bottom}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := decode(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 100 || bounds.Dy() != 90 {
+		t.Errorf("expected: 100x90, got: %dx%d", bounds.Dx(), bounds.Dy())
+	}
+	// the narrower image is centered on a white background
+	if r, _, _, _ := img.At(10, 60).RGBA(); r != 0xffff {
+		t.Errorf("expected: white background, got: %v", img.At(10, 60))
+	}
+	if r, _, _, _ := img.At(50, 60).RGBA(); r != 0 {
+		t.Errorf("expected: black image, got: %v", img.At(50, 60))
+	}
+}
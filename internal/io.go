@@ -29,3 +29,10 @@ func SortDirEntry(items []os.DirEntry) []os.DirEntry {
 	})
 	return items
 }
+
+// ImageExtensions are the extensions of the image files that can be read as manga pages.
+var ImageExtensions = []string{".jpg", ".jpeg", ".png", ".webp", ".gif", ".tif", ".tiff", ".avif"}
+
+func IsImageFile(name string) bool {
+	return slices.Contains(ImageExtensions, strings.ToLower(filepath.Ext(name)))
+}
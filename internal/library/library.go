@@ -0,0 +1,260 @@
+package library
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/maruel/natural"
+	"github.com/radam9/manga-tools/internal/model"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dirName  = "manga-tools"
+	fileName = "library.json"
+)
+
+// Catalog is the index of the files of the library, it's saved as json.
+type Catalog struct {
+	Files []File `json:"files"`
+}
+
+// File is a comic archive, pdf, epub or image folder of the library.
+type File struct {
+	Path      string          `json:"path"`
+	Format    string          `json:"format"`
+	Series    string          `json:"series"`
+	Title     string          `json:"title,omitempty"`
+	Volume    int             `json:"volume,omitempty"`
+	Chapters  []float64       `json:"chapters,omitempty"`
+	Language  string          `json:"language,omitempty"`
+	Groups    []string        `json:"groups,omitempty"`
+	Direction model.Direction `json:"direction,omitempty"`
+	Pages     int             `json:"pages,omitempty"`
+	Size      int64           `json:"size"`
+	ModTime   time.Time       `json:"modTime"`
+}
+
+// Series is the files of the catalog sharing a series title.
+type Series struct {
+	Title string
+	Files []File
+}
+
+// Path returns the path of the catalog, `$XDG_CONFIG_HOME/manga-tools/library.json`.
+func Path() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("finding config directory: %w", err)
+	}
+	return filepath.Join(dir, dirName, fileName), nil
+}
+
+// Load reads the catalog, a missing catalog is an empty one.
+func Load(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Catalog{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading library catalog %q: %w", path, err)
+	}
+
+	var catalog Catalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("parsing library catalog %q: %w", path, err)
+	}
+	return &catalog, nil
+}
+
+func (c *Catalog) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling library catalog: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating library catalog directory: %w", err)
+	}
+	// the catalog is replaced at once, so an interrupted save doesn't lose it.
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("writing library catalog %q: %w", path, err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Series returns the files grouped by series, the series titles are compared ignoring the case.
+func (c *Catalog) Series() []Series {
+	var result []Series
+	index := map[string]int{}
+	for _, file := range c.Files {
+		key := strings.ToLower(file.Series)
+		i, ok := index[key]
+		if !ok {
+			i = len(result)
+			index[key] = i
+			result = append(result, Series{Title: file.Series})
+		}
+		result[i].Files = append(result[i].Files, file)
+	}
+
+	slices.SortFunc(result, func(a, b Series) int {
+		return compareNatural(strings.ToLower(a.Title), strings.ToLower(b.Title))
+	})
+	for _, series := range result {
+		sortFiles(series.Files)
+	}
+	return result
+}
+
+// FindSeries returns the series with the given title, or the only series whose title contains it.
+func (c *Catalog) FindSeries(title string) (Series, error) {
+	query := strings.ToLower(strings.TrimSpace(title))
+	var matches []Series
+	for _, series := range c.Series() {
+		name := strings.ToLower(series.Title)
+		if name == query {
+			return series, nil
+		}
+		if strings.Contains(name, query) {
+			matches = append(matches, series)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return Series{}, fmt.Errorf("no series matching %q in the library", title)
+	case 1:
+		return matches[0], nil
+	}
+	var names []string
+	for _, series := range matches {
+		names = append(names, fmt.Sprintf("%q", series.Title))
+	}
+	return Series{}, fmt.Errorf("%q matches several series: %s", title, strings.Join(names, ", "))
+}
+
+func (s Series) Volumes() []int {
+	var result []int
+	for _, file := range s.Files {
+		if file.Volume > 0 && !slices.Contains(result, file.Volume) {
+			result = append(result, file.Volume)
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+func (s Series) Chapters() []float64 {
+	var result []float64
+	for _, file := range s.Files {
+		for _, chapter := range file.Chapters {
+			if !slices.Contains(result, chapter) {
+				result = append(result, chapter)
+			}
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+func (s Series) Languages() []string {
+	var result []string
+	for _, file := range s.Files {
+		if file.Language != "" && !slices.Contains(result, file.Language) {
+			result = append(result, file.Language)
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+func (s Series) Groups() []string {
+	var result []string
+	for _, file := range s.Files {
+		for _, group := range file.Groups {
+			if !slices.Contains(result, group) {
+				result = append(result, group)
+			}
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+// Has reports whether the series holds the chapter, or the whole volume if the chapter is 0.
+func (s Series) Has(volume int, chapter float64) bool {
+	for _, file := range s.Files {
+		if chapter == 0 && file.Volume == volume {
+			return true
+		}
+		if chapter > 0 && slices.Contains(file.Chapters, chapter) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortFiles sorts the files by volume, then by their first chapter, then by path.
+func sortFiles(files []File) {
+	slices.SortStableFunc(files, func(a, b File) int {
+		if a.Volume != b.Volume {
+			return a.Volume - b.Volume
+		}
+		aChapter, bChapter := firstChapter(a), firstChapter(b)
+		if aChapter < bChapter {
+			return -1
+		} else if aChapter > bChapter {
+			return 1
+		}
+		return compareNatural(a.Path, b.Path)
+	})
+}
+
+func firstChapter(file File) float64 {
+	if len(file.Chapters) == 0 {
+		return 0
+	}
+	return slices.Min(file.Chapters)
+}
+
+func compareNatural(a, b string) int {
+	if natural.Less(a, b) {
+		return -1
+	} else if natural.Less(b, a) {
+		return 1
+	}
+	return 0
+}
+
+// FormatRanges joins the chapter or volume numbers, the runs of consecutive numbers are written as ranges,
+// e.g. "1-10, 12, 12.5".
+func FormatRanges(numbers []float64) string {
+	var parts []string
+	for i := 0; i < len(numbers); i++ {
+		start := numbers[i]
+		for i+1 < len(numbers) && start == float64(int(start)) && numbers[i+1] == numbers[i]+1 {
+			i++
+		}
+		part := strconv.FormatFloat(start, 'f', -1, 64)
+		if numbers[i] != start {
+			part += "-" + strconv.FormatFloat(numbers[i], 'f', -1, 64)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// FormatVolumes joins the volume numbers like FormatRanges.
+func FormatVolumes(volumes []int) string {
+	var numbers []float64
+	for _, volume := range volumes {
+		numbers = append(numbers, float64(volume))
+	}
+	return FormatRanges(numbers)
+}
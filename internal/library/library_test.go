@@ -0,0 +1,125 @@
+package library
+
+import (
+	"fmt"
+	"github.com/radam9/manga-tools/internal/format"
+	"github.com/radam9/manga-tools/internal/model"
+	"github.com/radam9/manga-tools/internal/testutil"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected nameInfo
+	}{
+		{name: "Slam Dunk - volume 2 - chapter 0010.0 - The Genius", expected: nameInfo{series: "Slam Dunk", volume: 2, chapter: 10, title: "The Genius"}},
+		{name: "Slam Dunk - Volume 2 - Chapter 10.5", expected: nameInfo{series: "Slam Dunk", volume: 2, chapter: 10.5}},
+		{name: "Chapter 3 - Bonus", expected: nameInfo{chapter: 3, title: "Bonus"}},
+		{name: "Kaguya-sama - Love is War", expected: nameInfo{series: "Kaguya-sama - Love is War"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := parseName(tt.name)
+			if result != tt.expected {
+				t.Errorf("expected: %+v, got: %+v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestFormatRanges(t *testing.T) {
+	result := FormatRanges([]float64{1, 2, 3, 5, 6, 6.5, 8})
+	if expected := "1-3, 5-6, 6.5, 8"; result != expected {
+		t.Errorf("expected: %s, got: %s", expected, result)
+	}
+}
+
+func TestScan(t *testing.T) {
+	pagesDir := t.TempDir()
+	var pages []model.Page
+	for i := range 2 {
+		path := filepath.Join(pagesDir, fmt.Sprintf("%d.png", i))
+		testutil.WritePNG(t, path, 10, 20)
+		pages = append(pages, model.Page{Number: i + 1, Path: path})
+	}
+
+	dir := t.TempDir()
+	manga := model.Manga{Title: "Slam Dunk"}
+	bundle := model.Book{Manga: manga, Volume: 1, Chapters: []model.Chapter{
+		{Number: 1, Language: "en", Groups: []string{"Shohoku"}, Pages: pages[:1]},
+		{Number: 2, Language: "en", Groups: []string{"Shohoku", "Ryonan"}, Pages: pages[1:]},
+	}}
+	bundlePath := format.CBZ{}.OutputPath(dir, manga.Title, 1, "", 0)
+	if err := (format.CBZ{}).Save(bundlePath, bundle); err != nil {
+		t.Fatal(err)
+	}
+	chapter := model.Book{Manga: manga, Volume: 2, Chapters: []model.Chapter{{Number: 3, Title: "Rebound", Pages: pages}}}
+	chapterPath := format.PDF{}.OutputPath(dir, manga.Title, 2, "Rebound", 3)
+	if err := (format.PDF{}).Save(chapterPath, chapter); err != nil {
+		t.Fatal(err)
+	}
+	folder := filepath.Join(dir, "Vagabond", "Volume 01", "Chapter 004")
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		t.Fatal(err)
+	}
+	testutil.WritePNG(t, filepath.Join(folder, "001.png"), 10, 20)
+
+	catalog := &Catalog{}
+	result, err := catalog.Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Added != 3 {
+		t.Errorf("expected: %d, got: %d", 3, result.Added)
+	}
+
+	t.Run("series", func(t *testing.T) {
+		series, err := catalog.FindSeries("slam")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if volumes := series.Volumes(); !slices.Equal(volumes, []int{1, 2}) {
+			t.Errorf("expected: %v, got: %v", []int{1, 2}, volumes)
+		}
+		if chapters := series.Chapters(); !slices.Equal(chapters, []float64{1, 2, 3}) {
+			t.Errorf("expected: %v, got: %v", []float64{1, 2, 3}, chapters)
+		}
+		if languages := series.Languages(); !slices.Equal(languages, []string{"en"}) {
+			t.Errorf("expected: %v, got: %v", []string{"en"}, languages)
+		}
+		if groups := series.Groups(); !slices.Equal(groups, []string{"Ryonan", "Shohoku"}) {
+			t.Errorf("expected: %v, got: %v", []string{"Ryonan", "Shohoku"}, groups)
+		}
+		if series.Files[1].Title != "Rebound" || series.Files[1].Pages != 2 {
+			t.Errorf("expected: %s with %d pages, got: %s with %d pages", "Rebound", 2, series.Files[1].Title, series.Files[1].Pages)
+		}
+	})
+
+	t.Run("image folder", func(t *testing.T) {
+		series, err := catalog.FindSeries("Vagabond")
+		if err != nil {
+			t.Fatal(err)
+		}
+		file := series.Files[0]
+		if file.Format != FormatImages || !slices.Equal(file.Chapters, []float64{4}) || file.Pages != 1 {
+			t.Errorf("expected: chapter 4 image folder with 1 page, got: %+v", file)
+		}
+	})
+
+	t.Run("rescan", func(t *testing.T) {
+		if err := os.Remove(chapterPath); err != nil {
+			t.Fatal(err)
+		}
+		result, err := catalog.Scan(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != (ScanResult{Removed: 1, Unchanged: 2}) {
+			t.Errorf("expected: %+v, got: %+v", ScanResult{Removed: 1, Unchanged: 2}, result)
+		}
+	})
+}
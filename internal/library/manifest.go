@@ -0,0 +1,144 @@
+package library
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/radam9/manga-tools/internal/model"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// ManifestName is the file name of the download manifests, download writes one into every directory it saves files to.
+const ManifestName = "manga-tools.json"
+
+// Manifest lists the files downloaded into its directory, with the metadata that some formats can't hold, e.g. the
+// language and the groups of image folders and pdfs.
+type Manifest struct {
+	Files []ManifestFile `json:"files"`
+}
+
+// ManifestFile is a downloaded file, its name is relative to the directory of the manifest.
+type ManifestFile struct {
+	Name      string          `json:"name"`
+	MangaID   string          `json:"mangaId,omitempty"`
+	Series    string          `json:"series"`
+	Title     string          `json:"title,omitempty"`
+	Volume    int             `json:"volume,omitempty"`
+	Chapters  []float64       `json:"chapters,omitempty"`
+	Language  string          `json:"language,omitempty"`
+	Groups    []string        `json:"groups,omitempty"`
+	Direction model.Direction `json:"direction,omitempty"`
+}
+
+// NewManifestFile describes the book saved to the file path.
+func NewManifestFile(filePath string, book model.Book) ManifestFile {
+	file := ManifestFile{
+		Name:      filepath.Base(filePath),
+		MangaID:   book.Manga.ID,
+		Series:    book.Manga.Title,
+		Volume:    book.Volume,
+		Direction: book.Manga.Direction,
+	}
+	if len(book.Chapters) == 1 {
+		file.Title = book.Chapters[0].Title
+	}
+	for _, chapter := range book.Chapters {
+		file.Chapters = appendChapter(file.Chapters, chapter.Number)
+		if file.Language == "" {
+			file.Language = chapter.Language
+		}
+		for _, group := range chapter.Groups {
+			if !slices.Contains(file.Groups, group) {
+				file.Groups = append(file.Groups, group)
+			}
+		}
+	}
+	slices.Sort(file.Chapters)
+	return file
+}
+
+// ReadManifest reads the manifest of the directory, a missing manifest is an empty one.
+func ReadManifest(dir string) (*Manifest, error) {
+	path := filepath.Join(dir, ManifestName)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Manifest{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading manifest %q: %w", path, err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing manifest %q: %w", path, err)
+	}
+	return &manifest, nil
+}
+
+// Find returns the entry of the file name.
+func (m *Manifest) Find(name string) (ManifestFile, bool) {
+	i := slices.IndexFunc(m.Files, func(file ManifestFile) bool { return file.Name == name })
+	if i < 0 {
+		return ManifestFile{}, false
+	}
+	return m.Files[i], true
+}
+
+// WriteManifests adds the saved books, by file path, to the manifests of their directories. The entries of files
+// saved again are replaced.
+func WriteManifests(books map[string]model.Book) error {
+	dirs := map[string][]ManifestFile{}
+	for filePath, book := range books {
+		dir := filepath.Dir(filePath)
+		dirs[dir] = append(dirs[dir], NewManifestFile(filePath, book))
+	}
+
+	for dir, files := range dirs {
+		manifest, err := ReadManifest(dir)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			manifest.Files = slices.DeleteFunc(manifest.Files, func(old ManifestFile) bool { return old.Name == file.Name })
+			manifest.Files = append(manifest.Files, file)
+		}
+		slices.SortFunc(manifest.Files, func(a, b ManifestFile) int { return compareNatural(a.Name, b.Name) })
+
+		data, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshaling manifest: %w", err)
+		}
+		path := filepath.Join(dir, ManifestName)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("writing manifest %q: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// apply fills in the fields of the file its metadata doesn't have.
+func (m ManifestFile) apply(file *File) {
+	if file.Series == "" {
+		file.Series = m.Series
+	}
+	if file.Title == "" {
+		file.Title = m.Title
+	}
+	if file.Volume == 0 {
+		file.Volume = m.Volume
+	}
+	if len(file.Chapters) == 0 {
+		file.Chapters = slices.Clone(m.Chapters)
+	}
+	if file.Language == "" {
+		file.Language = m.Language
+	}
+	if len(file.Groups) == 0 {
+		file.Groups = slices.Clone(m.Groups)
+	}
+	if file.Direction == "" {
+		file.Direction = m.Direction
+	}
+}
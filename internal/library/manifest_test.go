@@ -0,0 +1,62 @@
+package library
+
+import (
+	"github.com/radam9/manga-tools/internal/format"
+	"github.com/radam9/manga-tools/internal/model"
+	"github.com/radam9/manga-tools/internal/testutil"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestScanManifest(t *testing.T) {
+	page := filepath.Join(t.TempDir(), "001.png")
+	testutil.WritePNG(t, page, 10, 20)
+
+	dir := t.TempDir()
+	manga := model.Manga{ID: "319df2e2-e6a6-4e3a-a31c-68539c140a84", Title: "Slam Dunk", Direction: model.DirectionRTL}
+	book := model.Book{Manga: manga, Volume: 1, Chapters: []model.Chapter{
+		{Number: 1, Title: "Sakuragi", Language: "en", Groups: []string{"Shohoku"}, Pages: model.NewPagesFromPaths([]model.FilePath{page})},
+	}}
+	// image folders hold no metadata, and their name holds the chapter only.
+	folder := filepath.Join(dir, "Chapter 001")
+	if err := (format.Image{}).Save(folder, book); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteManifests(map[string]model.Book{folder: book}); err != nil {
+		t.Fatal(err)
+	}
+
+	catalog := &Catalog{}
+	if _, err := catalog.Scan(dir); err != nil {
+		t.Fatal(err)
+	}
+	series, err := catalog.FindSeries("Slam Dunk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := series.Files[0]
+	if file.Volume != 1 || file.Title != "Sakuragi" || file.Language != "en" || file.Direction != model.DirectionRTL {
+		t.Errorf("expected: volume 1 Sakuragi in en read rtl, got: %+v", file)
+	}
+	if !slices.Equal(file.Groups, []string{"Shohoku"}) {
+		t.Errorf("expected: %v, got: %v", []string{"Shohoku"}, file.Groups)
+	}
+	if !series.Has(1, 1) || !series.Has(1, 0) || series.Has(1, 2) {
+		t.Errorf("expected: chapter 1 and volume 1 only, got: %+v", series.Files)
+	}
+
+	t.Run("saved again", func(t *testing.T) {
+		book.Chapters[0].Groups = []string{"Ryonan"}
+		if err := WriteManifests(map[string]model.Book{folder: book}); err != nil {
+			t.Fatal(err)
+		}
+		manifest, err := ReadManifest(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(manifest.Files) != 1 || !slices.Equal(manifest.Files[0].Groups, []string{"Ryonan"}) {
+			t.Errorf("expected: the entry replaced, got: %+v", manifest.Files)
+		}
+	})
+}
@@ -0,0 +1,378 @@
+package library
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/radam9/manga-tools/internal"
+	"github.com/radam9/manga-tools/internal/archive"
+	"github.com/radam9/manga-tools/internal/format"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormatImages is the format of the image folders.
+const FormatImages = "images"
+
+// formats are the formats of the indexed files by extension.
+var formats = map[string]string{
+	".cbz":  "cbz",
+	".cbr":  "cbr",
+	".cb7":  "cb7",
+	".cbt":  "cbt",
+	".pdf":  "pdf",
+	".epub": "epub",
+}
+
+// ScanResult counts the files of a scan.
+type ScanResult struct {
+	Added     int
+	Updated   int
+	Removed   int
+	Unchanged int
+}
+
+// Scan indexes the files under the directory. The files already in the catalog are only read again if they changed,
+// and the files of the directory that are gone are removed from the catalog. The download manifests fill in what the
+// metadata of the files doesn't have.
+func (c *Catalog) Scan(dir string) (ScanResult, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return ScanResult{}, err
+	}
+
+	known := map[string]File{}
+	var kept []File
+	for _, file := range c.Files {
+		if file.Path == root || strings.HasPrefix(file.Path, root+string(filepath.Separator)) {
+			known[file.Path] = file
+		} else {
+			kept = append(kept, file)
+		}
+	}
+
+	var result ScanResult
+	var found []File
+	manifests := map[string]*Manifest{}
+	err = walk(root, func(file File) {
+		old, ok := known[file.Path]
+		delete(known, file.Path)
+		switch {
+		case ok && old.Size == file.Size && old.ModTime.Equal(file.ModTime):
+			found = append(found, old)
+			result.Unchanged++
+			return
+		case ok:
+			result.Updated++
+		default:
+			result.Added++
+		}
+		found = append(found, readFile(file, manifestEntry(manifests, file.Path)))
+	})
+	if err != nil {
+		return ScanResult{}, err
+	}
+	result.Removed = len(known)
+	c.Files = append(kept, found...)
+	return result, nil
+}
+
+// walk calls fn with the indexed files under the directory, and with the directories holding images as image folders.
+func walk(dir string, fn func(file File)) error {
+	children, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("listing directory %q contents: %w", dir, err)
+	}
+	children = internal.SortDirEntry(children)
+
+	folder := File{Path: dir, Format: FormatImages}
+	var subDirs []string
+	for _, child := range children {
+		childPath := filepath.Join(dir, child.Name())
+		if child.IsDir() {
+			subDirs = append(subDirs, childPath)
+			continue
+		}
+		info, err := child.Info()
+		if err != nil {
+			slog.Warn("skipping unreadable file", "path", childPath, "error", err)
+			continue
+		}
+		if internal.IsImageFile(child.Name()) {
+			folder.Pages++
+			folder.Size += info.Size()
+			folder.ModTime = latest(folder.ModTime, info.ModTime())
+		} else if fileFormat, ok := formats[strings.ToLower(filepath.Ext(child.Name()))]; ok {
+			fn(File{Path: childPath, Format: fileFormat, Size: info.Size(), ModTime: info.ModTime().UTC()})
+		}
+	}
+	if folder.Pages > 0 {
+		// adding or removing a page changes the directory, and replacing a page changes the page.
+		if info, err := os.Stat(dir); err == nil {
+			folder.ModTime = latest(folder.ModTime, info.ModTime())
+		}
+		fn(folder)
+	}
+
+	for _, subDir := range subDirs {
+		if err := walk(subDir, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func latest(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b.UTC()
+	}
+	return a.UTC()
+}
+
+// manifestEntry returns the download manifest entry of the file, or nil if it wasn't downloaded. The manifests are
+// read once per directory.
+func manifestEntry(manifests map[string]*Manifest, filePath string) *ManifestFile {
+	dir := filepath.Dir(filePath)
+	manifest, ok := manifests[dir]
+	if !ok {
+		var err error
+		if manifest, err = ReadManifest(dir); err != nil {
+			slog.Warn("skipping unreadable download manifest", "path", dir, "error", err)
+			manifest = &Manifest{}
+		}
+		manifests[dir] = manifest
+	}
+	entry, ok := manifest.Find(filepath.Base(filePath))
+	if !ok {
+		return nil
+	}
+	return &entry
+}
+
+// readFile reads the metadata of the file, the download manifest entry, then the name of the file fill in what the
+// metadata doesn't have.
+func readFile(file File, manifest *ManifestFile) File {
+	var err error
+	switch file.Format {
+	case FormatImages:
+	case "pdf":
+		err = readPDF(&file)
+	case "epub":
+		err = readEPUB(&file)
+	default:
+		err = readArchive(&file)
+	}
+	if err != nil {
+		slog.Warn("indexing file by its name, its metadata can't be read", "path", file.Path, "error", err)
+	}
+	if manifest != nil {
+		manifest.apply(&file)
+	}
+
+	name := filepath.Base(file.Path)
+	if file.Format != FormatImages {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	applyName(&file, name)
+	if file.Series == "" {
+		file.Series = parentSeries(file.Path)
+	}
+	slices.Sort(file.Chapters)
+	return file
+}
+
+// parentSeries returns the name of the closest parent directory that isn't a volume directory, as the series layouts
+// write the files into `<Series>/` and `<Series>/Volume NN/`.
+func parentSeries(filePath string) string {
+	dir := filepath.Dir(filePath)
+	for volumePattern.MatchString(filepath.Base(dir)) && filepath.Dir(dir) != dir {
+		dir = filepath.Dir(dir)
+	}
+	return filepath.Base(dir)
+}
+
+func readArchive(file *File) error {
+	r, err := archive.Open(file.Path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var info *format.ComicInfo
+	for {
+		name, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading archive: %w", err)
+		}
+		if internal.IsImageFile(name) {
+			file.Pages++
+		} else if strings.EqualFold(path.Base(name), "ComicInfo.xml") {
+			info = &format.ComicInfo{}
+			if err := xml.NewDecoder(r).Decode(info); err != nil {
+				return fmt.Errorf("parsing ComicInfo.xml: %w", err)
+			}
+		}
+	}
+	if info == nil {
+		return nil
+	}
+
+	file.Series = strings.TrimSpace(info.Series)
+	file.Title = strings.TrimSpace(info.Title)
+	file.Volume = info.Volume
+	file.Language = info.LanguageISO
+	file.Direction = info.Direction()
+	if number, err := strconv.ParseFloat(info.Number, 64); err == nil && number > 0 {
+		file.Chapters = appendChapter(file.Chapters, number)
+	}
+	// bundles mark the first page of every chapter.
+	for _, page := range info.Pages {
+		if page.Bookmark != "" {
+			file.Chapters = appendChapter(file.Chapters, parseName(page.Bookmark).chapter)
+		}
+	}
+	for _, group := range strings.Split(info.ScanInformation, ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			file.Groups = append(file.Groups, group)
+		}
+	}
+	return nil
+}
+
+func readPDF(file *File) error {
+	info, err := format.ReadPDFInfo(file.Path)
+	if err != nil {
+		return err
+	}
+	file.Pages = info.PageCount
+	file.Direction = info.Direction
+	applyName(file, info.Title)
+	// bundles have an outline entry per chapter, grouped by volume if they span several volumes.
+	var addOutline func(bookmarks []pdfcpu.Bookmark)
+	addOutline = func(bookmarks []pdfcpu.Bookmark) {
+		for _, bookmark := range bookmarks {
+			file.Chapters = appendChapter(file.Chapters, parseName(bookmark.Title).chapter)
+			addOutline(bookmark.Kids)
+		}
+	}
+	addOutline(info.Outline)
+	return nil
+}
+
+func readEPUB(file *File) error {
+	r, err := zip.OpenReader(file.Path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if internal.IsImageFile(f.Name) {
+			file.Pages++
+		}
+	}
+	for _, f := range r.File {
+		if !strings.EqualFold(path.Ext(f.Name), ".opf") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		var pkg struct {
+			Title    string `xml:"metadata>title"`
+			Language string `xml:"metadata>language"`
+		}
+		if err := xml.NewDecoder(rc).Decode(&pkg); err != nil {
+			return fmt.Errorf("parsing %s: %w", f.Name, err)
+		}
+		if pkg.Language != "und" {
+			file.Language = pkg.Language
+		}
+		applyName(file, pkg.Title)
+		return nil
+	}
+	return nil
+}
+
+var (
+	volumePattern  = regexp.MustCompile(`(?i)^(?:volume|vol\.?)\s*(\d+)$`)
+	chapterPattern = regexp.MustCompile(`(?i)^(?:chapter|ch\.?)\s*(\d+(?:\.\d+)?)$`)
+)
+
+type nameInfo struct {
+	series  string
+	volume  int
+	chapter float64
+	title   string
+}
+
+// parseName parses the names of the files written by manga-tools, e.g. "Slam Dunk - volume 2 - chapter 0010.0 - The
+// Genius", and the titles of their metadata, e.g. "Slam Dunk - Volume 2 - Chapter 10 - The Genius".
+func parseName(name string) nameInfo {
+	parts := strings.Split(name, " - ")
+	var info nameInfo
+	first, last := -1, -1
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if match := volumePattern.FindStringSubmatch(part); match != nil && info.volume == 0 {
+			info.volume, _ = strconv.Atoi(match[1])
+		} else if match := chapterPattern.FindStringSubmatch(part); match != nil && info.chapter == 0 {
+			info.chapter, _ = strconv.ParseFloat(match[1], 64)
+		} else {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		last = i
+	}
+	if first < 0 {
+		info.series = strings.TrimSpace(name)
+		return info
+	}
+	info.series = strings.TrimSpace(strings.Join(parts[:first], " - "))
+	info.title = strings.TrimSpace(strings.Join(parts[last+1:], " - "))
+	return info
+}
+
+// applyName fills the metadata the file doesn't have from the parsed name.
+func applyName(file *File, name string) {
+	if strings.TrimSpace(name) == "" {
+		return
+	}
+	info := parseName(name)
+	if file.Series == "" {
+		file.Series = info.series
+	}
+	if file.Volume == 0 {
+		file.Volume = info.volume
+	}
+	if len(file.Chapters) == 0 {
+		file.Chapters = appendChapter(file.Chapters, info.chapter)
+	}
+	if file.Title == "" {
+		file.Title = info.title
+	}
+}
+
+func appendChapter(chapters []float64, chapter float64) []float64 {
+	if chapter <= 0 || slices.Contains(chapters, chapter) {
+		return chapters
+	}
+	return append(chapters, chapter)
+}
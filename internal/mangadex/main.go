@@ -20,50 +20,112 @@ const baseURL = "https://mangadex.org"
 const pagingLimit = 500
 
 type Client struct {
-	title    string
+	manga    *model.Manga
 	mangaID  uuid.UUID
 	language string
 	// rateLimiter rate limiter for the '/at-home' endpoint which has a rate limit of 40 calls per minute,
 	// if we exceed this limit we get a 429, and the consequent chapters fail. This may eventually lead to an IP ban.
 	rateLimiter <-chan time.Time
+	bandwidth   *bandwidthLimiter
+	hosts       *hostLimiter
 }
 
-func NewClient(mangaID uuid.UUID, lang string) *Client {
+func NewClient(mangaID uuid.UUID, lang string, limits Limits) *Client {
 	// we set the rate limit at 39 calls per minute instead of 40 to make sure the rate limit is under the threshold,
 	// otherwise we occasionally get hit by the rate limiter.
-	return &Client{mangaID: mangaID, language: lang, rateLimiter: time.Tick(time.Minute / 39)}
+	return &Client{
+		mangaID:     mangaID,
+		language:    lang,
+		rateLimiter: time.Tick(time.Minute / 39),
+		bandwidth:   newBandwidthLimiter(limits.BytesPerSecond),
+		hosts:       newHostLimiter(limits.ConnectionsPerHost),
+	}
 }
 
 func (c *Client) FetchTitle() (string, error) {
-	if c.title != "" {
-		return c.title, nil
+	manga, err := c.FetchManga()
+	if err != nil {
+		return "", err
+	}
+	return manga.Title, nil
+}
+
+// FetchManga fetches the manga metadata, including the names of its authors and artists.
+func (c *Client) FetchManga() (model.Manga, error) {
+	if c.manga != nil {
+		return *c.manga, nil
 	}
 
-	u := fmt.Sprintf("https://api.mangadex.org/manga/%s", c.mangaID.String())
+	params := url.Values{}
+	params.Add("includes[]", "author")
+	params.Add("includes[]", "artist")
+	u := fmt.Sprintf("https://api.mangadex.org/manga/%s?%s", c.mangaID.String(), params.Encode())
 	rBody, err := request(http.MethodGet, u, baseURL)
 	if err != nil {
-		return "", err
+		return model.Manga{}, err
 	}
 	defer rBody.Close()
 
 	// decode json response
 	body := mangaResponse{}
 	if err = json.NewDecoder(rBody).Decode(&body); err != nil {
-		return "", err
+		return model.Manga{}, err
+	}
+
+	attributes := body.Data.Attributes
+	manga := model.Manga{
+		ID:               c.mangaID.String(),
+		Title:            attributes.Title["en"],
+		Description:      attributes.Description["en"],
+		OriginalLanguage: attributes.OriginalLanguage,
+		Year:             attributes.Year,
 	}
 
 	if c.language != "" {
-		trans := body.Data.Attributes.AltTitles.GetTitleByLang(c.language)
+		if trans := attributes.AltTitles.GetTitleByLang(c.language); trans != "" {
+			manga.Title = trans
+		}
+		if desc := attributes.Description[c.language]; desc != "" {
+			manga.Description = desc
+		}
+	}
+	// fallback to any title if there is no english one
+	if manga.Title == "" {
+		for _, title := range attributes.Title {
+			manga.Title = title
+			break
+		}
+	}
 
-		if trans != "" {
-			c.title = trans
-			return c.title, nil
+	for _, tag := range attributes.Tags {
+		manga.Tags = append(manga.Tags, tag.Attributes.Name["en"])
+	}
+	for _, rel := range body.Data.Relationships {
+		switch rel.Type {
+		case "author":
+			manga.Authors = append(manga.Authors, rel.Attributes.Name)
+		case "artist":
+			manga.Artists = append(manga.Artists, rel.Attributes.Name)
 		}
 	}
+	manga.Direction = readingDirection(manga.OriginalLanguage, manga.Tags)
+
+	c.manga = &manga
+	return manga, nil
+}
 
-	// fallback to english
-	c.title = body.Data.Attributes.Title["en"]
-	return c.title, nil
+// readingDirection guesses the reading direction of a manga, japanese mangas are read right to left
+// unless they are webtoons, everything else is read left to right.
+func readingDirection(originalLanguage string, tags []string) model.Direction {
+	if originalLanguage != "ja" {
+		return model.DirectionLTR
+	}
+	for _, tag := range tags {
+		if tag == "Long Strip" || tag == "Web Comic" {
+			return model.DirectionLTR
+		}
+	}
+	return model.DirectionRTL
 }
 
 func (c Client) FetchChapterList() ([]model.Chapter, []error) {
@@ -78,6 +140,7 @@ func (c Client) FetchChapterList() ([]model.Chapter, []error) {
 		params.Add("order[volume]", "asc")
 		params.Add("order[chapter]", "asc")
 		params.Add("offset", fmt.Sprint(offset))
+		params.Add("includes[]", "scanlation_group")
 		if c.language != "" {
 			params.Add("translatedLanguage[]", c.language)
 		}
@@ -99,14 +162,20 @@ func (c Client) FetchChapterList() ([]model.Chapter, []error) {
 		for _, c := range body.Data {
 			num, _ := strconv.ParseFloat(c.Attributes.Chapter, 64)
 			vol, _ := strconv.Atoi(c.Attributes.Volume)
-			chapters = append(chapters, model.Chapter{
+			chapter := model.Chapter{
 				ID:         c.Id,
 				Title:      c.Attributes.Title,
 				Number:     num,
 				Volume:     vol,
 				PagesCount: c.Attributes.Pages,
 				Language:   c.Attributes.TranslatedLanguage,
-			})
+			}
+			for _, rel := range c.Relationships {
+				if rel.Type == "scanlation_group" && rel.Attributes.Name != "" {
+					chapter.Groups = append(chapter.Groups, rel.Attributes.Name)
+				}
+			}
+			chapters = append(chapters, chapter)
 		}
 
 		if len(body.Data) == 0 {
@@ -145,6 +214,7 @@ func (c Client) FetchChapterInfo(chapter *model.Chapter) error {
 
 func (c Client) FetchChapterPages(chapterNumber float64, chapterID string, pages []model.Page, maxPagesConcurrency int) ([]model.Page, error) {
 	wg := sync.WaitGroup{}
+	mu := sync.Mutex{}
 	var result []model.Page
 
 	slog.Info("downloading pages", "chapterNumber", chapterNumber, "chapterID", chapterID)
@@ -155,6 +225,7 @@ func (c Client) FetchChapterPages(chapterNumber float64, chapterID string, pages
 		wg.Add(1)
 		go func(page model.Page) {
 			defer wg.Done()
+			defer func() { <-guard }()
 
 			data, err := c.FetchFile(page.URL, baseURL)
 			if err != nil {
@@ -162,9 +233,9 @@ func (c Client) FetchChapterPages(chapterNumber float64, chapterID string, pages
 				return
 			}
 
+			mu.Lock()
 			result = append(result, model.Page{Number: page.Number, URL: page.URL, Data: data})
-
-			<-guard
+			mu.Unlock()
 		}(page)
 	}
 	wg.Wait()
@@ -174,7 +245,13 @@ func (c Client) FetchChapterPages(chapterNumber float64, chapterID string, pages
 	return result, nil
 }
 
+// FetchFile downloads the file, within the bandwidth and the connections per host limits of the client.
 func (c Client) FetchFile(uri string, referer string) (io.Reader, error) {
+	if u, err := url.Parse(uri); err == nil {
+		release := c.hosts.acquire(u.Host)
+		defer release()
+	}
+
 	body, err := request(http.MethodGet, uri, referer)
 	if err != nil {
 		return nil, err
@@ -182,7 +259,7 @@ func (c Client) FetchFile(uri string, referer string) (io.Reader, error) {
 	defer body.Close()
 
 	var data bytes.Buffer
-	_, err = io.Copy(&data, body)
+	_, err = io.Copy(&data, c.bandwidth.reader(body))
 	if err != nil {
 		return nil, err
 	}
@@ -194,8 +271,22 @@ type mangaResponse struct {
 	Id   string
 	Data struct {
 		Attributes struct {
-			Title     map[string]string
-			AltTitles altTitles
+			Title            map[string]string
+			AltTitles        altTitles
+			Description      map[string]string
+			OriginalLanguage string
+			Year             int
+			Tags             []struct {
+				Attributes struct {
+					Name map[string]string
+				}
+			}
+		}
+		Relationships []struct {
+			Type       string
+			Attributes struct {
+				Name string
+			}
 		}
 	}
 }
@@ -224,6 +315,12 @@ type feedReponse struct {
 			TranslatedLanguage string
 			Pages              int
 		}
+		Relationships []struct {
+			Type       string
+			Attributes struct {
+				Name string
+			}
+		}
 	}
 }
 
@@ -0,0 +1,105 @@
+package mangadex
+
+import (
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limits are the limits of the page downloads.
+type Limits struct {
+	// BytesPerSecond caps the bandwidth of all the page downloads together, zero disables it.
+	BytesPerSecond int64
+	// ConnectionsPerHost caps the concurrent page downloads from a single at-home server, zero disables it.
+	ConnectionsPerHost int
+}
+
+// bandwidthLimiter is a token bucket of bytes shared by all the downloads, it holds at most one second of tokens.
+type bandwidthLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newBandwidthLimiter(bytesPerSecond int64) *bandwidthLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &bandwidthLimiter{rate: float64(bytesPerSecond), tokens: float64(bytesPerSecond), last: time.Now()}
+}
+
+// wait takes n bytes from the bucket, and blocks until they would have been available.
+// The bucket goes negative, so the downloads waiting after this one wait for these bytes too.
+func (l *bandwidthLimiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	time.Sleep(delay)
+}
+
+// reader returns a reader of r limited by the bucket.
+func (l *bandwidthLimiter) reader(r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &throttledReader{r: r, limiter: l}
+}
+
+type throttledReader struct {
+	r       io.Reader
+	limiter *bandwidthLimiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	// small reads keep the downloads running side by side, rather than one download taking the whole second.
+	const maxRead = 32 * 1024
+	if len(p) > maxRead {
+		p = p[:maxRead]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		t.limiter.wait(n)
+	}
+	return n, err
+}
+
+// hostLimiter caps the concurrent connections to every host.
+type hostLimiter struct {
+	mu    sync.Mutex
+	max   int
+	hosts map[string]chan struct{}
+}
+
+func newHostLimiter(connectionsPerHost int) *hostLimiter {
+	if connectionsPerHost <= 0 {
+		return nil
+	}
+	return &hostLimiter{max: connectionsPerHost, hosts: map[string]chan struct{}{}}
+}
+
+// acquire blocks until a connection to the host is available, the returned function releases it.
+func (h *hostLimiter) acquire(host string) func() {
+	if h == nil {
+		return func() {}
+	}
+	h.mu.Lock()
+	slots, found := h.hosts[host]
+	if !found {
+		slots = make(chan struct{}, h.max)
+		h.hosts[host] = slots
+	}
+	h.mu.Unlock()
+
+	slots <- struct{}{}
+	return func() { <-slots }
+}
@@ -0,0 +1,64 @@
+package mangadex
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBandwidthLimiter(t *testing.T) {
+	const rate = 1 << 20
+	limiter := newBandwidthLimiter(rate)
+
+	// the bucket starts full, so the second half megabyte is what has to wait.
+	start := time.Now()
+	wg := sync.WaitGroup{}
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := io.Copy(io.Discard, limiter.reader(bytes.NewReader(make([]byte, rate/2)))); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the wait is 500ms, with some slack for the timers.
+	if minimum, elapsed := 400*time.Millisecond, time.Since(start); elapsed < minimum {
+		t.Errorf("expected: at least %s, got: %s", minimum, elapsed)
+	}
+}
+
+func TestHostLimiter(t *testing.T) {
+	limiter := newHostLimiter(2)
+	var current, highest atomic.Int32
+
+	wg := sync.WaitGroup{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release := limiter.acquire("uploads.mangadex.org")
+			defer release()
+
+			n := current.Add(1)
+			for {
+				h := highest.Load()
+				if n <= h || highest.CompareAndSwap(h, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			current.Add(-1)
+		}()
+	}
+	wg.Wait()
+
+	if got := highest.Load(); got != 2 {
+		t.Errorf("expected: 2 connections, got: %d", got)
+	}
+}
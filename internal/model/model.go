@@ -1,11 +1,46 @@
 package model
 
 import (
+	"cmp"
+	"fmt"
 	"github.com/radam9/manga-tools/internal/ranges"
 	"io"
 	"slices"
+	"strconv"
+	"strings"
 )
 
+type Manga struct {
+	ID               string
+	Title            string
+	Description      string
+	Authors          []string
+	Artists          []string
+	OriginalLanguage string
+	Tags             []string
+	Year             int
+	Direction        Direction
+}
+
+// Direction is the reading direction of a manga.
+type Direction string
+
+const (
+	DirectionLTR Direction = "ltr"
+	DirectionRTL Direction = "rtl"
+)
+
+var Directions = []Direction{DirectionLTR, DirectionRTL}
+
+func ParseDirection(s string) (Direction, error) {
+	for _, direction := range Directions {
+		if strings.EqualFold(s, string(direction)) {
+			return direction, nil
+		}
+	}
+	return "", fmt.Errorf("unknown reading direction %q, must be one of %v", s, Directions)
+}
+
 type Chapter struct {
 	ID         string
 	Title      string
@@ -14,6 +49,20 @@ type Chapter struct {
 	PagesCount int
 	Pages      []Page
 	Language   string
+	// Groups are the scanlation groups of the chapter.
+	Groups []string
+}
+
+// Label returns the display name of the chapter, e.g. "Chapter 87 - The Genius".
+func (c Chapter) Label() string {
+	var parts []string
+	if c.Number > 0 {
+		parts = append(parts, fmt.Sprintf("Chapter %s", strconv.FormatFloat(c.Number, 'f', -1, 64)))
+	}
+	if c.Title != "" {
+		parts = append(parts, c.Title)
+	}
+	return strings.Join(parts, " - ")
 }
 
 type Page struct {
@@ -21,10 +70,52 @@ type Page struct {
 	URL    string
 	Data   io.Reader
 	Path   FilePath
+	// DoublePage is set for the double-page spreads.
+	DoublePage bool
 }
 
 type FilePath = string
 
+// Book is the content of a single output file, it holds one chapter or a bundle of chapters.
+type Book struct {
+	Manga    Manga
+	Volume   int
+	Chapters []Chapter
+}
+
+// Title returns the display name of the book, e.g. "Slam Dunk - Volume 2 - Chapter 10 - The Genius".
+func (b Book) Title() string {
+	var parts []string
+	if b.Manga.Title != "" {
+		parts = append(parts, b.Manga.Title)
+	}
+	if b.Volume > 0 {
+		parts = append(parts, fmt.Sprintf("Volume %d", b.Volume))
+	}
+	if len(b.Chapters) == 1 {
+		if label := b.Chapters[0].Label(); label != "" && label != b.Manga.Title {
+			parts = append(parts, label)
+		}
+	}
+	return strings.Join(parts, " - ")
+}
+
+func (b Book) PagePaths() []FilePath {
+	var result []FilePath
+	for _, chapter := range b.Chapters {
+		result = append(result, GetSliceOfPagePathsFromPages(chapter.Pages)...)
+	}
+	return result
+}
+
+func (b Book) PagesCount() int {
+	count := 0
+	for _, chapter := range b.Chapters {
+		count += len(chapter.Pages)
+	}
+	return count
+}
+
 func SortChaptersByNumber(chapters []Chapter) {
 	slices.SortStableFunc(chapters, func(a, b Chapter) int {
 		if a.Number < b.Number {
@@ -66,3 +157,46 @@ func GetSliceOfPagePathsFromPages(pages []Page) []FilePath {
 	}
 	return result
 }
+
+func NewPagesFromPaths(paths []FilePath) []Page {
+	result := make([]Page, 0, len(paths))
+	for i, path := range paths {
+		result = append(result, Page{Number: i + 1, Path: path})
+	}
+	return result
+}
+
+// MergeBooks joins the chapters of the books into a single book. The manga metadata is taken from the first book
+// that has it, the creators and tags of every book are kept, and the volume is kept if the books share it.
+func MergeBooks(books []Book) Book {
+	var result Book
+	volumes := map[int]bool{}
+	for _, book := range books {
+		manga := &result.Manga
+		manga.ID = cmp.Or(manga.ID, book.Manga.ID)
+		manga.Title = cmp.Or(manga.Title, book.Manga.Title)
+		manga.Description = cmp.Or(manga.Description, book.Manga.Description)
+		manga.OriginalLanguage = cmp.Or(manga.OriginalLanguage, book.Manga.OriginalLanguage)
+		manga.Year = cmp.Or(manga.Year, book.Manga.Year)
+		manga.Direction = cmp.Or(manga.Direction, book.Manga.Direction)
+		manga.Authors = appendMissing(manga.Authors, book.Manga.Authors...)
+		manga.Artists = appendMissing(manga.Artists, book.Manga.Artists...)
+		manga.Tags = appendMissing(manga.Tags, book.Manga.Tags...)
+
+		volumes[book.Volume] = true
+		result.Chapters = append(result.Chapters, book.Chapters...)
+	}
+	if len(volumes) == 1 && len(books) > 0 {
+		result.Volume = books[0].Volume
+	}
+	return result
+}
+
+func appendMissing(values []string, items ...string) []string {
+	for _, item := range items {
+		if !slices.Contains(values, item) {
+			values = append(values, item)
+		}
+	}
+	return values
+}
@@ -0,0 +1,352 @@
+package server
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/radam9/manga-tools/internal/library"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the OPDS 1.2 catalog, see https://specs.opds.io/opds-1.2 and https://github.com/anansi-project/opds-pse
+const (
+	navigationType  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	acquisitionType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	openSearchType  = "application/opensearchdescription+xml"
+
+	relAcquisition = "http://opds-spec.org/acquisition"
+	relImage       = "http://opds-spec.org/image"
+	relThumbnail   = "http://opds-spec.org/image/thumbnail"
+	relPSEStream   = "http://vaemendis.net/opds-pse/stream"
+)
+
+type atomFeed struct {
+	XMLName         xml.Name    `xml:"feed"`
+	XMLNS           string      `xml:"xmlns,attr"`
+	XMLNSOPDS       string      `xml:"xmlns:opds,attr"`
+	XMLNSDC         string      `xml:"xmlns:dc,attr"`
+	XMLNSPSE        string      `xml:"xmlns:pse,attr"`
+	XMLNSOpenSearch string      `xml:"xmlns:opensearch,attr"`
+	ID              string      `xml:"id"`
+	Title           string      `xml:"title"`
+	Updated         string      `xml:"updated"`
+	Author          atomAuthor  `xml:"author"`
+	Links           []atomLink  `xml:"link"`
+	Entries         []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title    string       `xml:"title"`
+	ID       string       `xml:"id"`
+	Updated  string       `xml:"updated"`
+	Language string       `xml:"dc:language,omitempty"`
+	Content  *atomContent `xml:"content"`
+	Links    []atomLink   `xml:"link"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+type atomLink struct {
+	Rel   string `xml:"rel,attr,omitempty"`
+	Href  string `xml:"href,attr"`
+	Type  string `xml:"type,attr,omitempty"`
+	Title string `xml:"title,attr,omitempty"`
+	// PSECount is the number of pages of the page streaming links.
+	PSECount int `xml:"pse:count,attr,omitempty"`
+}
+
+type openSearchDescription struct {
+	XMLName        xml.Name      `xml:"OpenSearchDescription"`
+	XMLNS          string        `xml:"xmlns,attr"`
+	ShortName      string        `xml:"ShortName"`
+	Description    string        `xml:"Description"`
+	InputEncoding  string        `xml:"InputEncoding"`
+	OutputEncoding string        `xml:"OutputEncoding"`
+	URL            openSearchURL `xml:"Url"`
+}
+
+type openSearchURL struct {
+	Type     string `xml:"type,attr"`
+	Template string `xml:"template,attr"`
+}
+
+func (s *Server) registerOPDS(mux *http.ServeMux) {
+	mux.HandleFunc("GET /opds", s.handleOPDSRoot)
+	mux.HandleFunc("GET /opds/series/{id}", s.handleOPDSSeries)
+	mux.HandleFunc("GET /opds/series/{id}/volumes/{volume}", s.handleOPDSVolume)
+	mux.HandleFunc("GET /opds/search", s.handleOPDSSearch)
+	mux.HandleFunc("GET /opds/search.xml", s.handleOpenSearch)
+}
+
+func newFeed(id, title, self, kind string) atomFeed {
+	return atomFeed{
+		XMLNS:           "http://www.w3.org/2005/Atom",
+		XMLNSOPDS:       "http://opds-spec.org/2010/catalog",
+		XMLNSDC:         "http://purl.org/dc/terms/",
+		XMLNSPSE:        "http://vaemendis.net/opds-pse/ns",
+		XMLNSOpenSearch: "http://a9.com/-/spec/opensearch/1.1/",
+		ID:              "urn:manga-tools:" + id,
+		Title:           title,
+		Updated:         time.Now().UTC().Format(time.RFC3339),
+		Author:          atomAuthor{Name: "manga-tools"},
+		Links: []atomLink{
+			{Rel: "self", Href: self, Type: kind},
+			{Rel: "start", Href: "/opds", Type: navigationType},
+			{Rel: "search", Href: "/opds/search.xml", Type: openSearchType},
+		},
+	}
+}
+
+func writeXML(w http.ResponseWriter, contentType string, v any) {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		httpError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	if _, err := w.Write(append([]byte(xml.Header), data...)); err != nil {
+		slog.Warn("failed to write response", "error", err)
+	}
+}
+
+// handleOPDSRoot serves the navigation feed of the series.
+func (s *Server) handleOPDSRoot(w http.ResponseWriter, r *http.Request) {
+	catalog, err := s.library()
+	if err != nil {
+		httpError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	feed := newFeed("root", "manga-tools library", "/opds", navigationType)
+	for _, series := range catalog.Series() {
+		id := seriesID(series.Title)
+		summary := countFiles(len(series.Files))
+		if volumes := series.Volumes(); len(volumes) > 0 {
+			summary += fmt.Sprintf(", volumes %s", library.FormatVolumes(volumes))
+		}
+		if chapters := series.Chapters(); len(chapters) > 0 {
+			summary += fmt.Sprintf(", chapters %s", library.FormatRanges(chapters))
+		}
+		cover := fileID(series.Files[0].Path)
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:   series.Title,
+			ID:      "urn:manga-tools:series:" + id,
+			Updated: latestUpdate(series.Files),
+			Content: &atomContent{Type: "text", Text: summary},
+			Links: []atomLink{
+				{Rel: "subsection", Href: "/opds/series/" + id, Type: navigationType},
+				{Rel: relImage, Href: "/files/" + cover + "/cover"},
+				{Rel: relThumbnail, Href: "/files/" + cover + "/thumbnail", Type: "image/jpeg"},
+			},
+		})
+	}
+	writeXML(w, navigationType, feed)
+}
+
+// findSeries returns the series of the id in the request path.
+func (s *Server) findSeries(w http.ResponseWriter, r *http.Request) (library.Series, bool) {
+	catalog, err := s.library()
+	if err != nil {
+		httpError(w, err, http.StatusInternalServerError)
+		return library.Series{}, false
+	}
+	id := r.PathValue("id")
+	for _, series := range catalog.Series() {
+		if seriesID(series.Title) == id {
+			return series, true
+		}
+	}
+	http.NotFound(w, r)
+	return library.Series{}, false
+}
+
+// handleOPDSSeries serves the navigation feed of the volumes of a series, the files without a volume are grouped
+// under volume 0.
+func (s *Server) handleOPDSSeries(w http.ResponseWriter, r *http.Request) {
+	series, ok := s.findSeries(w, r)
+	if !ok {
+		return
+	}
+
+	id := seriesID(series.Title)
+	feed := newFeed("series:"+id, series.Title, "/opds/series/"+id, navigationType)
+	var volumes []int
+	filesByVolume := map[int][]library.File{}
+	for _, file := range series.Files {
+		if _, ok := filesByVolume[file.Volume]; !ok {
+			volumes = append(volumes, file.Volume)
+		}
+		filesByVolume[file.Volume] = append(filesByVolume[file.Volume], file)
+	}
+	for _, volume := range volumes {
+		files := filesByVolume[volume]
+		title := fmt.Sprintf("Volume %d", volume)
+		if volume == 0 {
+			title = "Without volume"
+		}
+		summary := countFiles(len(files))
+		if chapters := (library.Series{Files: files}).Chapters(); len(chapters) > 0 {
+			summary += fmt.Sprintf(", chapters %s", library.FormatRanges(chapters))
+		}
+		cover := fileID(files[0].Path)
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:   title,
+			ID:      fmt.Sprintf("urn:manga-tools:series:%s:volume:%d", id, volume),
+			Updated: latestUpdate(files),
+			Content: &atomContent{Type: "text", Text: summary},
+			Links: []atomLink{
+				{Rel: "subsection", Href: fmt.Sprintf("/opds/series/%s/volumes/%d", id, volume), Type: acquisitionType},
+				{Rel: relImage, Href: "/files/" + cover + "/cover"},
+				{Rel: relThumbnail, Href: "/files/" + cover + "/thumbnail", Type: "image/jpeg"},
+			},
+		})
+	}
+	writeXML(w, navigationType, feed)
+}
+
+// handleOPDSVolume serves the acquisition feed of the files of a volume.
+func (s *Server) handleOPDSVolume(w http.ResponseWriter, r *http.Request) {
+	series, ok := s.findSeries(w, r)
+	if !ok {
+		return
+	}
+	volume, err := strconv.Atoi(r.PathValue("volume"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	id := seriesID(series.Title)
+	title := series.Title
+	if volume > 0 {
+		title = fmt.Sprintf("%s - Volume %d", series.Title, volume)
+	}
+	feed := newFeed(fmt.Sprintf("series:%s:volume:%d", id, volume), title, r.URL.Path, acquisitionType)
+	for _, file := range series.Files {
+		if file.Volume == volume {
+			feed.Entries = append(feed.Entries, fileEntry(file))
+		}
+	}
+	if len(feed.Entries) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	writeXML(w, acquisitionType, feed)
+}
+
+// handleOPDSSearch serves the acquisition feed of the files whose series, title or name contains the search terms.
+func (s *Server) handleOPDSSearch(w http.ResponseWriter, r *http.Request) {
+	catalog, err := s.library()
+	if err != nil {
+		httpError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	terms := strings.Fields(strings.ToLower(query))
+	feed := newFeed("search:"+query, fmt.Sprintf("Search: %s", query), r.URL.RequestURI(), acquisitionType)
+	for _, series := range catalog.Series() {
+		for _, file := range series.Files {
+			text := strings.ToLower(strings.Join([]string{file.Series, file.Title, filepath.Base(file.Path)}, " "))
+			matches := len(terms) > 0
+			for _, term := range terms {
+				matches = matches && strings.Contains(text, term)
+			}
+			if matches {
+				feed.Entries = append(feed.Entries, fileEntry(file))
+			}
+		}
+	}
+	writeXML(w, acquisitionType, feed)
+}
+
+// handleOpenSearch serves the OpenSearch description of the search, its template is absolute as some readers
+// don't resolve it against the description url.
+func (s *Server) handleOpenSearch(w http.ResponseWriter, r *http.Request) {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	writeXML(w, openSearchType, openSearchDescription{
+		XMLNS:          "http://a9.com/-/spec/opensearch/1.1/",
+		ShortName:      "manga-tools",
+		Description:    "Search the series and files of the manga-tools library",
+		InputEncoding:  "UTF-8",
+		OutputEncoding: "UTF-8",
+		URL: openSearchURL{
+			Type:     acquisitionType,
+			Template: fmt.Sprintf("%s://%s/opds/search?q={searchTerms}", scheme, r.Host),
+		},
+	})
+}
+
+// fileEntry returns the acquisition entry of a file, with its cover, and a page streaming link for the readers
+// supporting OPDS-PSE. The image folders can only be streamed.
+func fileEntry(file library.File) atomEntry {
+	id := fileID(file.Path)
+	name := filepath.Base(file.Path)
+	if file.Format != library.FormatImages {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	var summary []string
+	if len(file.Chapters) > 0 {
+		summary = append(summary, fmt.Sprintf("chapters %s", library.FormatRanges(file.Chapters)))
+	}
+	if file.Pages > 0 {
+		summary = append(summary, fmt.Sprintf("%d pages", file.Pages))
+	}
+	summary = append(summary, file.Format)
+
+	entry := atomEntry{
+		Title:    name,
+		ID:       "urn:manga-tools:file:" + id,
+		Updated:  file.ModTime.UTC().Format(time.RFC3339),
+		Language: file.Language,
+		Content:  &atomContent{Type: "text", Text: strings.Join(summary, ", ")},
+	}
+	if mediaType, ok := mediaTypes[file.Format]; ok {
+		entry.Links = append(entry.Links, atomLink{Rel: relAcquisition, Href: "/files/" + id, Type: mediaType})
+	}
+	entry.Links = append(entry.Links,
+		atomLink{Rel: relImage, Href: "/files/" + id + "/cover"},
+		atomLink{Rel: relThumbnail, Href: "/files/" + id + "/thumbnail", Type: "image/jpeg"},
+	)
+	if file.Format != "epub" && file.Pages > 0 {
+		entry.Links = append(entry.Links, atomLink{
+			Rel:      relPSEStream,
+			Href:     "/files/" + id + "/pages/{pageNumber}",
+			Type:     "image/jpeg",
+			PSECount: file.Pages,
+		})
+	}
+	return entry
+}
+
+func countFiles(n int) string {
+	if n == 1 {
+		return "1 file"
+	}
+	return fmt.Sprintf("%d files", n)
+}
+
+func latestUpdate(files []library.File) string {
+	var latest time.Time
+	for _, file := range files {
+		if file.ModTime.After(latest) {
+			latest = file.ModTime
+		}
+	}
+	return latest.UTC().Format(time.RFC3339)
+}
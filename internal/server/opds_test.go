@@ -0,0 +1,198 @@
+package server
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/radam9/manga-tools/internal/format"
+	"github.com/radam9/manga-tools/internal/model"
+	"github.com/radam9/manga-tools/internal/testutil"
+	"image"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestLibrary writes a library of a cbz and a pdf of the same series, and an image folder of another series.
+func newTestLibrary(t *testing.T) string {
+	t.Helper()
+	pagesDir := t.TempDir()
+	var pages []model.Page
+	for i := range 2 {
+		path := filepath.Join(pagesDir, fmt.Sprintf("%d.png", i))
+		testutil.WritePNG(t, path, 10, 20)
+		pages = append(pages, model.Page{Number: i + 1, Path: path})
+	}
+
+	dir := t.TempDir()
+	manga := model.Manga{Title: "Slam Dunk"}
+	book := model.Book{Manga: manga, Volume: 1, Chapters: []model.Chapter{{Number: 1, Language: "en", Pages: pages}}}
+	if err := (format.CBZ{}).Save(format.CBZ{}.OutputPath(dir, manga.Title, 1, "", 1), book); err != nil {
+		t.Fatal(err)
+	}
+	book = model.Book{Manga: manga, Volume: 2, Chapters: []model.Chapter{{Number: 2, Pages: pages}}}
+	if err := (format.PDF{}).Save(format.PDF{}.OutputPath(dir, manga.Title, 2, "", 2), book); err != nil {
+		t.Fatal(err)
+	}
+	folder := filepath.Join(dir, "Vagabond - chapter 1")
+	if err := os.Mkdir(folder, 0755); err != nil {
+		t.Fatal(err)
+	}
+	testutil.WritePNG(t, filepath.Join(folder, "001.png"), 10, 20)
+	return dir
+}
+
+func newTestServer(t *testing.T, options Options) *httptest.Server {
+	t.Helper()
+	s, err := New(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewServer(s.Handler())
+}
+
+func get(t *testing.T, srv *httptest.Server, path string) (*http.Response, []byte) {
+	t.Helper()
+	resp, err := srv.Client().Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %d, got: %d for %s: %s", http.StatusOK, resp.StatusCode, path, body)
+	}
+	return resp, body
+}
+
+func getFeed(t *testing.T, srv *httptest.Server, path, kind string) atomFeed {
+	t.Helper()
+	resp, body := get(t, srv, path)
+	if contentType := resp.Header.Get("Content-Type"); contentType != kind {
+		t.Errorf("expected: %s, got: %s", kind, contentType)
+	}
+	var feed atomFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		t.Fatal(err)
+	}
+	return feed
+}
+
+func findLink(entry atomEntry, rel string) (atomLink, bool) {
+	for _, link := range entry.Links {
+		if link.Rel == rel {
+			return link, true
+		}
+	}
+	return atomLink{}, false
+}
+
+func TestOPDS(t *testing.T) {
+	srv := newTestServer(t, Options{Dir: newTestLibrary(t), OPDS: true})
+	defer srv.Close()
+
+	root := getFeed(t, srv, "/opds", navigationType)
+	if len(root.Entries) != 2 || root.Entries[0].Title != "Slam Dunk" || root.Entries[1].Title != "Vagabond" {
+		t.Fatalf("expected: the series Slam Dunk and Vagabond, got: %+v", root.Entries)
+	}
+
+	seriesLink, _ := findLink(root.Entries[0], "subsection")
+	series := getFeed(t, srv, seriesLink.Href, navigationType)
+	if len(series.Entries) != 2 || series.Entries[0].Title != "Volume 1" || series.Entries[1].Title != "Volume 2" {
+		t.Fatalf("expected: volumes 1 and 2, got: %+v", series.Entries)
+	}
+
+	t.Run("acquisition", func(t *testing.T) {
+		volumeLink, _ := findLink(series.Entries[0], "subsection")
+		volume := getFeed(t, srv, volumeLink.Href, acquisitionType)
+		if len(volume.Entries) != 1 {
+			t.Fatalf("expected: %d, got: %d", 1, len(volume.Entries))
+		}
+		entry := volume.Entries[0]
+		acquisition, ok := findLink(entry, relAcquisition)
+		if !ok || acquisition.Type != mediaTypes["cbz"] {
+			t.Fatalf("expected: a cbz acquisition link, got: %+v", entry.Links)
+		}
+		resp, body := get(t, srv, acquisition.Href)
+		if !bytes.HasPrefix(body, []byte("PK")) || !strings.Contains(resp.Header.Get("Content-Disposition"), ".cbz") {
+			t.Errorf("expected: the cbz file, got: %s", resp.Header.Get("Content-Disposition"))
+		}
+
+		thumbnail, _ := findLink(entry, relThumbnail)
+		_, body = get(t, srv, thumbnail.Href)
+		if _, err := jpeg.Decode(bytes.NewReader(body)); err != nil {
+			t.Errorf("expected: a jpeg thumbnail, got: %v", err)
+		}
+	})
+
+	t.Run("page streaming", func(t *testing.T) {
+		search := getFeed(t, srv, "/opds/search?q=slam", acquisitionType)
+		if len(search.Entries) != 2 {
+			t.Fatalf("expected: %d, got: %d", 2, len(search.Entries))
+		}
+		for _, entry := range search.Entries {
+			stream, ok := findLink(entry, relPSEStream)
+			if !ok {
+				t.Fatalf("expected: a page streaming link, got: %+v", entry.Links)
+			}
+			_, body := get(t, srv, strings.Replace(stream.Href, "{pageNumber}", "1", 1))
+			if _, _, err := image.Decode(bytes.NewReader(body)); err != nil {
+				t.Errorf("expected: page 2 of %s, got: %v", entry.Title, err)
+			}
+		}
+		_, body := get(t, srv, "/opds/search?q=slam")
+		if !strings.Contains(string(body), `pse:count="2"`) {
+			t.Errorf("expected: the page count of the streaming links, got: %s", body)
+		}
+	})
+
+	t.Run("opensearch", func(t *testing.T) {
+		_, body := get(t, srv, "/opds/search.xml")
+		if !strings.Contains(string(body), srv.URL+"/opds/search?q={searchTerms}") {
+			t.Errorf("expected: the search template, got: %s", body)
+		}
+	})
+}
+
+func TestBasicAuth(t *testing.T) {
+	srv := newTestServer(t, Options{Dir: newTestLibrary(t), OPDS: true, User: "reader", Password: "secret"})
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		expected int
+	}{
+		{name: "no credentials", expected: http.StatusUnauthorized},
+		{name: "wrong password", user: "reader", password: "wrong", expected: http.StatusUnauthorized},
+		{name: "credentials", user: "reader", password: "secret", expected: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/opds", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, tt.password)
+			}
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.expected {
+				t.Errorf("expected: %d, got: %d", tt.expected, resp.StatusCode)
+			}
+		})
+	}
+}
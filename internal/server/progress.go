@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const progressFileName = "progress.json"
+
+// ProgressPath returns the path of the reading positions, `$XDG_CONFIG_HOME/manga-tools/progress.json`.
+func ProgressPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("finding config directory: %w", err)
+	}
+	return filepath.Join(dir, "manga-tools", progressFileName), nil
+}
+
+// readingPosition is the page a file was last read at, the pages are numbered from 0.
+type readingPosition struct {
+	Page    int       `json:"page"`
+	Updated time.Time `json:"updated"`
+}
+
+// progress keeps the reading position of every file by path, it's saved to a json file if it has a path.
+type progress struct {
+	mu        sync.Mutex
+	path      string
+	positions map[string]readingPosition
+}
+
+func loadProgress(path string) (*progress, error) {
+	p := &progress{path: path, positions: map[string]readingPosition{}}
+	if path == "" {
+		return p, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return p, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading progress file %q: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &p.positions); err != nil {
+		return nil, fmt.Errorf("parsing progress file %q: %w", path, err)
+	}
+	return p, nil
+}
+
+func (p *progress) get(filePath string) (readingPosition, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	position, ok := p.positions[filePath]
+	return position, ok
+}
+
+func (p *progress) set(filePath string, page int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.positions[filePath] = readingPosition{Page: page, Updated: time.Now().UTC()}
+	if p.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(p.positions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling progress: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(p.path), 0755); err != nil {
+		return fmt.Errorf("creating progress directory: %w", err)
+	}
+	tmpPath := p.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("writing progress file %q: %w", p.path, err)
+	}
+	return os.Rename(tmpPath, p.path)
+}
@@ -0,0 +1,304 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"github.com/radam9/manga-tools/internal/format"
+	"github.com/radam9/manga-tools/internal/images"
+	"github.com/radam9/manga-tools/internal/library"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// rescanInterval is how old the catalog can get before the directory is scanned again, only the changed files
+	// are read again.
+	rescanInterval = time.Minute
+	// maxOpenFiles is how many files have their pages kept listed.
+	maxOpenFiles    = 8
+	thumbnailWidth  = 300
+	thumbnailHeight = 450
+)
+
+// mediaTypes are the media types of the downloaded files by format.
+var mediaTypes = map[string]string{
+	"cbz":  "application/vnd.comicbook+zip",
+	"cbr":  "application/vnd.comicbook-rar",
+	"cb7":  "application/x-cb7",
+	"cbt":  "application/x-cbt",
+	"pdf":  "application/pdf",
+	"epub": "application/epub+zip",
+}
+
+type Options struct {
+	// Dir is the library directory that is served.
+	Dir  string
+	OPDS bool
+	// User and Password protect the server with http basic auth, the server is open if the password is empty.
+	User     string
+	Password string
+	// ProgressPath is the file the reading positions are saved to, they're only kept in memory if it's empty.
+	ProgressPath string
+}
+
+// Server serves the files of a library directory, their pages one at a time, and the web reader.
+type Server struct {
+	options  Options
+	progress *progress
+
+	// scanning is held by the scan of the directory, so it's only scanned once at a time.
+	scanning sync.Mutex
+
+	mu      sync.Mutex
+	catalog *library.Catalog
+	scanned time.Time
+	pages   map[string]openFile
+}
+
+type openFile struct {
+	modTime time.Time
+	pages   format.Pages
+}
+
+func New(options Options) (*Server, error) {
+	progress, err := loadProgress(options.ProgressPath)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{options: options, progress: progress, pages: map[string]openFile{}}, nil
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /files/{id}", s.handleFile)
+	mux.HandleFunc("GET /files/{id}/cover", s.handleCover)
+	mux.HandleFunc("GET /files/{id}/thumbnail", s.handleThumbnail)
+	mux.HandleFunc("GET /files/{id}/pages/{page}", s.handlePage)
+	s.registerWeb(mux)
+	if s.options.OPDS {
+		s.registerOPDS(mux)
+	}
+	return s.basicAuth(mux)
+}
+
+func (s *Server) basicAuth(next http.Handler) http.Handler {
+	if s.options.Password == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(user), []byte(s.options.User)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(s.options.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="manga-tools", charset="UTF-8"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// library returns the catalog of the served directory. Once the catalog gets old the directory is scanned again in
+// the background, and the old catalog is returned until the scan is done, so the requests don't wait for the whole
+// library to be read again. A new catalog is made by every scan, so the catalogs being read aren't changed.
+func (s *Server) library() (*library.Catalog, error) {
+	s.mu.Lock()
+	catalog, scanned := s.catalog, s.scanned
+	s.mu.Unlock()
+	if catalog != nil {
+		if time.Since(scanned) >= rescanInterval && s.scanning.TryLock() {
+			go func() {
+				defer s.scanning.Unlock()
+				if _, err := s.scan(); err != nil {
+					slog.Warn("failed to scan library", "path", s.options.Dir, "error", err)
+				}
+			}()
+		}
+		return catalog, nil
+	}
+
+	// the first requests wait for the first scan.
+	s.scanning.Lock()
+	defer s.scanning.Unlock()
+	s.mu.Lock()
+	catalog = s.catalog
+	s.mu.Unlock()
+	if catalog != nil {
+		return catalog, nil
+	}
+	return s.scan()
+}
+
+// scan scans the directory into a new catalog, only the files changed since the last scan are read again.
+func (s *Server) scan() (*library.Catalog, error) {
+	catalog := &library.Catalog{}
+	s.mu.Lock()
+	if s.catalog != nil {
+		catalog.Files = slices.Clone(s.catalog.Files)
+	}
+	s.mu.Unlock()
+
+	result, err := catalog.Scan(s.options.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if result.Added > 0 || result.Updated > 0 || result.Removed > 0 {
+		slog.Info("scanned library", "path", s.options.Dir, "added", result.Added, "updated", result.Updated, "removed", result.Removed)
+	}
+	s.mu.Lock()
+	s.catalog, s.scanned = catalog, time.Now()
+	s.mu.Unlock()
+	return catalog, nil
+}
+
+// fileID is the id of a file in the urls, derived from its path so it's stable across scans.
+func fileID(path string) string {
+	return hashID(path)
+}
+
+func seriesID(title string) string {
+	return hashID(strings.ToLower(title))
+}
+
+func hashID(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:8])
+}
+
+// findFile returns the file of the id in the request path.
+func (s *Server) findFile(w http.ResponseWriter, r *http.Request) (library.File, bool) {
+	catalog, err := s.library()
+	if err != nil {
+		httpError(w, err, http.StatusInternalServerError)
+		return library.File{}, false
+	}
+	id := r.PathValue("id")
+	for _, file := range catalog.Files {
+		if fileID(file.Path) == id {
+			return file, true
+		}
+	}
+	http.NotFound(w, r)
+	return library.File{}, false
+}
+
+// openPages returns the pages of the file, the pages of the recently read files are kept listed.
+func (s *Server) openPages(file library.File) (format.Pages, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	open, ok := s.pages[file.Path]
+	if ok && open.modTime.Equal(file.ModTime) {
+		return open.pages, nil
+	}
+	if ok {
+		open.pages.Close()
+		delete(s.pages, file.Path)
+	}
+
+	pages, err := format.OpenPages(file.Path)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.pages) >= maxOpenFiles {
+		for path, open := range s.pages {
+			open.pages.Close()
+			delete(s.pages, path)
+			break
+		}
+	}
+	s.pages[file.Path] = openFile{modTime: file.ModTime, pages: pages}
+	return pages, nil
+}
+
+func (s *Server) readPage(file library.File, page int) (string, []byte, error) {
+	pages, err := s.openPages(file)
+	if err != nil {
+		return "", nil, err
+	}
+	return pages.Read(page)
+}
+
+func (s *Server) handleFile(w http.ResponseWriter, r *http.Request) {
+	file, ok := s.findFile(w, r)
+	if !ok {
+		return
+	}
+	if file.Format == library.FormatImages {
+		http.Error(w, "image folders can only be read page by page", http.StatusNotFound)
+		return
+	}
+
+	f, err := os.Open(file.Path)
+	if err != nil {
+		httpError(w, err, http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	name := filepath.Base(file.Path)
+	w.Header().Set("Content-Type", mediaTypes[file.Format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	http.ServeContent(w, r, name, file.ModTime, f)
+}
+
+// handlePage serves a page of the file, the pages are numbered from 0.
+func (s *Server) handlePage(w http.ResponseWriter, r *http.Request) {
+	file, ok := s.findFile(w, r)
+	if !ok {
+		return
+	}
+	page, err := strconv.Atoi(r.PathValue("page"))
+	if err != nil || page < 0 || (file.Pages > 0 && page >= file.Pages) {
+		http.NotFound(w, r)
+		return
+	}
+	s.servePage(w, r, file, page)
+}
+
+func (s *Server) handleCover(w http.ResponseWriter, r *http.Request) {
+	if file, ok := s.findFile(w, r); ok {
+		s.servePage(w, r, file, 0)
+	}
+}
+
+func (s *Server) servePage(w http.ResponseWriter, r *http.Request, file library.File, page int) {
+	name, data, err := s.readPage(file, page)
+	if err != nil {
+		httpError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	http.ServeContent(w, r, name, file.ModTime, bytes.NewReader(data))
+}
+
+func (s *Server) handleThumbnail(w http.ResponseWriter, r *http.Request) {
+	file, ok := s.findFile(w, r)
+	if !ok {
+		return
+	}
+	_, data, err := s.readPage(file, 0)
+	if err != nil {
+		httpError(w, err, http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if err := images.Thumbnail(&buf, bytes.NewReader(data), thumbnailWidth, thumbnailHeight); err != nil {
+		httpError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	http.ServeContent(w, r, "thumbnail.jpg", file.ModTime, bytes.NewReader(buf.Bytes()))
+}
+
+func httpError(w http.ResponseWriter, err error, status int) {
+	slog.Warn("failed to serve request", "error", err)
+	http.Error(w, err.Error(), status)
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"github.com/radam9/manga-tools/internal/testutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLibraryRescan(t *testing.T) {
+	dir := newTestLibrary(t)
+	s, err := New(Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog, err := s.library()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(catalog.Files) != 3 {
+		t.Fatalf("expected: 3 files, got: %d", len(catalog.Files))
+	}
+
+	folder := filepath.Join(dir, "Vagabond - chapter 2")
+	if err := os.Mkdir(folder, 0755); err != nil {
+		t.Fatal(err)
+	}
+	testutil.WritePNG(t, filepath.Join(folder, "001.png"), 10, 20)
+	s.mu.Lock()
+	s.scanned = time.Now().Add(-rescanInterval)
+	s.mu.Unlock()
+
+	// the old catalog is returned while the directory is scanned again.
+	old, err := s.library()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old != catalog {
+		t.Errorf("expected: the old catalog, got: a catalog of %d files", len(old.Files))
+	}
+	s.scanning.Lock()
+	s.scanning.Unlock()
+	catalog, err = s.library()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(catalog.Files) != 4 {
+		t.Errorf("expected: 4 files, got: %d", len(catalog.Files))
+	}
+}
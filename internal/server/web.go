@@ -0,0 +1,209 @@
+package server
+
+import (
+	"embed"
+	"encoding/json"
+	"github.com/radam9/manga-tools/internal/library"
+	"github.com/radam9/manga-tools/internal/model"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// webFiles is the web reader, a page reading the library through the json api.
+//
+//go:embed web
+var webFiles embed.FS
+
+type seriesResponse struct {
+	ID       string         `json:"id"`
+	Title    string         `json:"title"`
+	Cover    string         `json:"cover"`
+	Volumes  string         `json:"volumes"`
+	Chapters string         `json:"chapters"`
+	Files    []fileResponse `json:"files,omitempty"`
+}
+
+type fileResponse struct {
+	ID        string          `json:"id"`
+	Title     string          `json:"title"`
+	Volume    int             `json:"volume,omitempty"`
+	Chapters  string          `json:"chapters"`
+	Format    string          `json:"format"`
+	Pages     int             `json:"pages"`
+	Readable  bool            `json:"readable"`
+	Direction model.Direction `json:"direction,omitempty"`
+	// Page is the page the file was last read at, -1 if it wasn't read.
+	Page int `json:"page"`
+}
+
+type readerResponse struct {
+	fileResponse
+	Series   seriesResponse `json:"series"`
+	Previous string         `json:"previous,omitempty"`
+	Next     string         `json:"next,omitempty"`
+}
+
+type progressRequest struct {
+	Page int `json:"page"`
+}
+
+func (s *Server) registerWeb(mux *http.ServeMux) {
+	static, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		panic(err)
+	}
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFileFS(w, r, static, "index.html")
+	})
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServerFS(static)))
+	mux.HandleFunc("GET /api/series", s.handleAPISeriesList)
+	mux.HandleFunc("GET /api/series/{id}", s.handleAPISeries)
+	mux.HandleFunc("GET /api/files/{id}", s.handleAPIFile)
+	mux.HandleFunc("PUT /api/files/{id}/progress", s.handleAPIProgress)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("failed to write response", "error", err)
+	}
+}
+
+func newSeriesResponse(series library.Series) seriesResponse {
+	return seriesResponse{
+		ID:       seriesID(series.Title),
+		Title:    series.Title,
+		Cover:    "/files/" + fileID(series.Files[0].Path) + "/thumbnail",
+		Volumes:  library.FormatVolumes(series.Volumes()),
+		Chapters: library.FormatRanges(series.Chapters()),
+	}
+}
+
+func (s *Server) newFileResponse(file library.File) fileResponse {
+	name := filepath.Base(file.Path)
+	if file.Format != library.FormatImages {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	response := fileResponse{
+		ID:        fileID(file.Path),
+		Title:     name,
+		Volume:    file.Volume,
+		Chapters:  library.FormatRanges(file.Chapters),
+		Format:    file.Format,
+		Pages:     file.Pages,
+		Readable:  file.Format != "epub" && file.Pages > 0,
+		Direction: file.Direction,
+		Page:      -1,
+	}
+	if position, ok := s.progress.get(file.Path); ok {
+		response.Page = position.Page
+	}
+	return response
+}
+
+func (s *Server) handleAPISeriesList(w http.ResponseWriter, r *http.Request) {
+	catalog, err := s.library()
+	if err != nil {
+		httpError(w, err, http.StatusInternalServerError)
+		return
+	}
+	result := []seriesResponse{}
+	for _, series := range catalog.Series() {
+		result = append(result, newSeriesResponse(series))
+	}
+	writeJSON(w, result)
+}
+
+func (s *Server) handleAPISeries(w http.ResponseWriter, r *http.Request) {
+	series, ok := s.findSeries(w, r)
+	if !ok {
+		return
+	}
+	response := newSeriesResponse(series)
+	for _, file := range series.Files {
+		response.Files = append(response.Files, s.newFileResponse(file))
+	}
+	writeJSON(w, response)
+}
+
+// handleAPIFile returns the file to read with the files read before and after it in the series, the page count is
+// the one of the file rather than the catalog as the catalog can be older than the file.
+func (s *Server) handleAPIFile(w http.ResponseWriter, r *http.Request) {
+	file, ok := s.findFile(w, r)
+	if !ok {
+		return
+	}
+	catalog, err := s.library()
+	if err != nil {
+		httpError(w, err, http.StatusInternalServerError)
+		return
+	}
+	series, err := catalog.FindSeries(file.Series)
+	if err != nil {
+		httpError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	response := readerResponse{fileResponse: s.newFileResponse(file), Series: newSeriesResponse(series)}
+	if response.Readable {
+		pages, err := s.openPages(file)
+		if err != nil {
+			httpError(w, err, http.StatusInternalServerError)
+			return
+		}
+		response.Pages = pages.Count()
+	}
+	var readable []library.File
+	for _, seriesFile := range series.Files {
+		if seriesFile.Format != "epub" && seriesFile.Pages > 0 {
+			readable = append(readable, seriesFile)
+		}
+	}
+	for i, seriesFile := range readable {
+		if seriesFile.Path != file.Path {
+			continue
+		}
+		if i > 0 {
+			response.Previous = fileID(readable[i-1].Path)
+		}
+		if i+1 < len(readable) {
+			response.Next = fileID(readable[i+1].Path)
+		}
+	}
+	writeJSON(w, response)
+}
+
+// handleAPIProgress saves the page the file was read at.
+func (s *Server) handleAPIProgress(w http.ResponseWriter, r *http.Request) {
+	file, ok := s.findFile(w, r)
+	if !ok {
+		return
+	}
+	var request progressRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Page < 0 {
+		http.Error(w, "invalid reading position", http.StatusBadRequest)
+		return
+	}
+	// the reading position must be a page of the file.
+	if !s.newFileResponse(file).Readable {
+		http.Error(w, "invalid reading position", http.StatusBadRequest)
+		return
+	}
+	pages, err := s.openPages(file)
+	if err != nil {
+		httpError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if request.Page >= pages.Count() {
+		http.Error(w, "invalid reading position", http.StatusBadRequest)
+		return
+	}
+	if err := s.progress.set(file.Path, request.Page); err != nil {
+		httpError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
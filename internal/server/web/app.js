@@ -0,0 +1,252 @@
+"use strict";
+
+// the web reader of manga-tools, the views are picked from the url hash:
+//   #/               the series of the library
+//   #/series/ID      the files of a series
+//   #/read/ID        the reader of a file
+
+const content = document.getElementById("content");
+const title = document.getElementById("title");
+const back = document.getElementById("back");
+const controls = document.getElementById("controls");
+const position = document.getElementById("position");
+const previousLink = document.getElementById("previous");
+const nextLink = document.getElementById("next");
+const modeSelect = document.getElementById("mode");
+const rtlCheckbox = document.getElementById("rtl");
+
+// reader is the state of the open file, null outside of the reader.
+let reader = null;
+
+async function api(path, options) {
+  const response = await fetch(path, options);
+  if (!response.ok) {
+    throw new Error(`${response.status} ${await response.text()}`);
+  }
+  return response.status === 204 ? null : response.json();
+}
+
+function element(tag, properties, ...children) {
+  const el = Object.assign(document.createElement(tag), properties);
+  el.append(...children);
+  return el;
+}
+
+function pageURL(id, page) {
+  return `/files/${id}/pages/${page}`;
+}
+
+async function route() {
+  window.removeEventListener("scroll", onWebtoonScroll);
+  reader = null;
+  controls.hidden = true;
+  content.replaceChildren();
+  const [, view, id] = location.hash.split("/");
+  try {
+    if (view === "series") {
+      await showSeries(id);
+    } else if (view === "read") {
+      await showReader(id);
+    } else {
+      await showLibrary();
+    }
+  } catch (error) {
+    content.replaceChildren(element("p", {textContent: `Failed to load: ${error.message}`}));
+  }
+}
+
+async function showLibrary() {
+  title.textContent = "manga-tools";
+  back.hidden = true;
+  const series = await api("/api/series");
+  if (series.length === 0) {
+    content.append(element("p", {textContent: "The library is empty."}));
+    return;
+  }
+  content.append(element("div", {className: "grid"}, ...series.map((s) => element("a", {className: "card", href: `#/series/${s.id}`},
+    element("img", {src: s.cover, loading: "lazy", alt: ""}),
+    element("span", {textContent: s.title}),
+    element("span", {className: "muted", textContent: [s.volumes && `vol. ${s.volumes}`, s.chapters && `ch. ${s.chapters}`].filter(Boolean).join(", ")}),
+  ))));
+}
+
+async function showSeries(id) {
+  const series = await api(`/api/series/${id}`);
+  title.textContent = series.title;
+  back.hidden = false;
+  back.href = "#/";
+  back.textContent = "Library";
+  content.append(element("ul", {className: "files"}, ...series.files.map((file) => {
+    const name = file.readable
+      ? element("a", {href: `#/read/${file.id}`, textContent: file.title})
+      : element("a", {href: `/files/${file.id}`, textContent: file.title, title: "download"});
+    let state = `${file.pages} pages, ${file.format}`;
+    if (file.page >= 0) {
+      state = `page ${file.page + 1} of ${file.pages}, ${file.format}`;
+    }
+    return element("li", {}, name, element("span", {className: "muted", textContent: state}));
+  })));
+}
+
+// settings are the reading mode and direction of a series, kept by the browser.
+function loadSettings(file) {
+  const saved = JSON.parse(localStorage.getItem(`settings:${file.series.id}`) || "null");
+  return saved || {mode: "single", rtl: file.direction === "rtl"};
+}
+
+function saveSettings() {
+  localStorage.setItem(`settings:${reader.file.series.id}`, JSON.stringify(reader.settings));
+}
+
+async function showReader(id) {
+  const file = await api(`/api/files/${id}`);
+  title.textContent = file.title;
+  back.hidden = false;
+  back.href = `#/series/${file.series.id}`;
+  back.textContent = file.series.title;
+  previousLink.hidden = !file.previous;
+  previousLink.href = `#/read/${file.previous}`;
+  nextLink.hidden = !file.next;
+  nextLink.href = `#/read/${file.next}`;
+  controls.hidden = false;
+
+  reader = {file, settings: loadSettings(file), page: Math.min(Math.max(file.page, 0), file.pages - 1), saveTimer: null};
+  modeSelect.value = reader.settings.mode;
+  rtlCheckbox.checked = reader.settings.rtl;
+  render();
+}
+
+// spread returns the pages shown together in double page mode, the first page is the cover and is shown alone.
+function spread(page) {
+  if (page === 0) {
+    return [0];
+  }
+  const first = page % 2 === 1 ? page : page - 1;
+  return first + 1 < reader.file.pages ? [first, first + 1] : [first];
+}
+
+function render() {
+  window.removeEventListener("scroll", onWebtoonScroll);
+  const {file, settings} = reader;
+  if (settings.mode === "webtoon") {
+    const pages = element("div", {className: "webtoon"});
+    for (let page = 0; page < file.pages; page++) {
+      const image = element("img", {src: pageURL(file.id, page), loading: "lazy", alt: `page ${page + 1}`});
+      image.dataset.page = page;
+      pages.append(image);
+    }
+    content.replaceChildren(pages);
+    pages.children[reader.page]?.scrollIntoView();
+    window.addEventListener("scroll", onWebtoonScroll, {passive: true});
+    updatePosition();
+    return;
+  }
+
+  const shown = settings.mode === "double" ? spread(reader.page) : [reader.page];
+  const pages = element("div", {className: `pages ${settings.mode}${settings.rtl ? " rtl" : ""}`},
+    ...shown.map((page) => element("img", {src: pageURL(file.id, page), alt: `page ${page + 1}`})));
+  pages.addEventListener("click", (event) => {
+    const left = event.clientX < window.innerWidth / 2;
+    turn(left === settings.rtl ? 1 : -1);
+  });
+  content.replaceChildren(pages);
+  window.scrollTo(0, 0);
+  // the next pages are loaded ahead.
+  for (let page = shown[shown.length - 1] + 1; page < Math.min(file.pages, shown[shown.length - 1] + 3); page++) {
+    new Image().src = pageURL(file.id, page);
+  }
+  updatePosition();
+}
+
+// turn moves by a page, or by a spread in double page mode, and opens the next chapter past the last page.
+function turn(direction) {
+  const {file, settings} = reader;
+  let page = reader.page;
+  if (settings.mode === "double") {
+    const shown = spread(page);
+    page = direction > 0 ? shown[shown.length - 1] + 1 : spread(Math.max(shown[0] - 1, 0))[0];
+  } else {
+    page += direction;
+  }
+  if (page >= file.pages) {
+    if (file.next) {
+      location.hash = `#/read/${file.next}`;
+    }
+    return;
+  }
+  if (page < 0) {
+    return;
+  }
+  reader.page = page;
+  render();
+}
+
+function onWebtoonScroll() {
+  const images = content.querySelectorAll(".webtoon img");
+  for (const image of images) {
+    if (image.getBoundingClientRect().bottom > window.innerHeight / 3) {
+      const page = Number(image.dataset.page);
+      if (page !== reader.page) {
+        reader.page = page;
+        updatePosition();
+      }
+      return;
+    }
+  }
+}
+
+// updatePosition shows the page, and saves it once the reader stops turning pages.
+function updatePosition() {
+  const {file, settings} = reader;
+  const shown = settings.mode === "double" ? spread(reader.page) : [reader.page];
+  position.textContent = `${shown.map((page) => page + 1).join("-")} / ${file.pages}`;
+
+  clearTimeout(reader.saveTimer);
+  const current = reader;
+  reader.saveTimer = setTimeout(() => {
+    api(`/api/files/${current.file.id}/progress`, {
+      method: "PUT",
+      headers: {"Content-Type": "application/json"},
+      body: JSON.stringify({page: current.page}),
+    }).catch((error) => console.warn("saving reading position", error));
+  }, 500);
+}
+
+modeSelect.addEventListener("change", () => {
+  reader.settings.mode = modeSelect.value;
+  saveSettings();
+  render();
+});
+
+rtlCheckbox.addEventListener("change", () => {
+  reader.settings.rtl = rtlCheckbox.checked;
+  saveSettings();
+  render();
+});
+
+document.addEventListener("keydown", (event) => {
+  if (!reader || reader.settings.mode === "webtoon" || event.target instanceof HTMLSelectElement) {
+    return;
+  }
+  switch (event.key) {
+    case "ArrowRight":
+      turn(reader.settings.rtl ? -1 : 1);
+      break;
+    case "ArrowLeft":
+      turn(reader.settings.rtl ? 1 : -1);
+      break;
+    case " ":
+    case "PageDown":
+      turn(1);
+      break;
+    case "PageUp":
+      turn(-1);
+      break;
+    default:
+      return;
+  }
+  event.preventDefault();
+});
+
+window.addEventListener("hashchange", route);
+route();
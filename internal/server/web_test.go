@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func getJSON(t *testing.T, srv *httptest.Server, path string, v any) {
+	t.Helper()
+	_, body := get(t, srv, path)
+	if err := json.Unmarshal(body, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func putProgress(t *testing.T, srv *httptest.Server, id, body string) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPut, srv.URL+"/api/files/"+id+"/progress", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestWeb(t *testing.T) {
+	dir := newTestLibrary(t)
+	progressPath := filepath.Join(t.TempDir(), "progress.json")
+	srv := newTestServer(t, Options{Dir: dir, ProgressPath: progressPath})
+	defer srv.Close()
+
+	t.Run("index", func(t *testing.T) {
+		resp, body := get(t, srv, "/")
+		if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/html") {
+			t.Errorf("expected: text/html, got: %s", contentType)
+		}
+		if !strings.Contains(string(body), "/static/app.js") {
+			t.Errorf("expected: the index to load /static/app.js, got: %s", body)
+		}
+		get(t, srv, "/static/app.js")
+	})
+
+	var library []seriesResponse
+	getJSON(t, srv, "/api/series", &library)
+	if len(library) != 2 || library[0].Title != "Slam Dunk" || library[1].Title != "Vagabond" {
+		t.Fatalf("expected: the series Slam Dunk and Vagabond, got: %+v", library)
+	}
+	var series seriesResponse
+	getJSON(t, srv, "/api/series/"+library[0].ID, &series)
+	if len(series.Files) != 2 {
+		t.Fatalf("expected: 2 files, got: %+v", series.Files)
+	}
+	cbz, pdf := series.Files[0], series.Files[1]
+
+	t.Run("reader", func(t *testing.T) {
+		var file readerResponse
+		getJSON(t, srv, "/api/files/"+cbz.ID, &file)
+		if file.Pages != 2 || file.Page != -1 || !file.Readable {
+			t.Errorf("expected: 2 unread pages, got: %d pages at page %d", file.Pages, file.Page)
+		}
+		if file.Previous != "" || file.Next != pdf.ID {
+			t.Errorf("expected: next %s, got: previous %s, next %s", pdf.ID, file.Previous, file.Next)
+		}
+		if file.Series.ID != library[0].ID {
+			t.Errorf("expected: %s, got: %s", library[0].ID, file.Series.ID)
+		}
+	})
+
+	t.Run("progress", func(t *testing.T) {
+		for _, body := range []string{`{"page": -1}`, `{"page": 2}`} {
+			if status := putProgress(t, srv, pdf.ID, body); status != http.StatusBadRequest {
+				t.Errorf("expected: %d for %s, got: %d", http.StatusBadRequest, body, status)
+			}
+		}
+		if status := putProgress(t, srv, pdf.ID, `{"page": 1}`); status != http.StatusNoContent {
+			t.Fatalf("expected: %d, got: %d", http.StatusNoContent, status)
+		}
+		var file readerResponse
+		getJSON(t, srv, "/api/files/"+pdf.ID, &file)
+		if file.Page != 1 {
+			t.Errorf("expected: 1, got: %d", file.Page)
+		}
+
+		// the reading positions are kept by a new server.
+		restarted := newTestServer(t, Options{Dir: dir, ProgressPath: progressPath})
+		defer restarted.Close()
+		getJSON(t, restarted, "/api/files/"+pdf.ID, &file)
+		if file.Page != 1 {
+			t.Errorf("expected: 1, got: %d", file.Page)
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package internal
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{input: "512", expected: 512},
+		{input: "300K", expected: 300 << 10},
+		{input: "25MB", expected: 25 << 20},
+		{input: "2mb", expected: 2 << 20},
+		{input: "1.5GiB", expected: 3 << 29},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSize(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected: %d, got: %d", tt.expected, got)
+			}
+		})
+	}
+
+	if _, err := ParseSize("fast"); err == nil {
+		t.Errorf("expected an error for an invalid size")
+	}
+}
@@ -0,0 +1,22 @@
+// Package testutil holds the helpers shared by the tests of several packages.
+package testutil
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"testing"
+)
+
+// WritePNG writes a blank png page of the given size.
+func WritePNG(t testing.TB, path string, width, height int) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+}